@@ -55,3 +55,16 @@ func NewAlert() Alert {
 	a := Alert{}
 	return a
 }
+
+//IsEmpty returns true if no property of the Alert is set.
+func (a *Alert) IsEmpty() bool {
+	return a.Title == "" && a.Subtitle == "" && a.TitleLocKey == "" && len(a.TitleLocArgs) == 0 &&
+		a.Body == "" && a.LocKey == "" && len(a.LocArgs) == 0 && a.ActionLocKey == "" && a.LaunchImage == ""
+}
+
+//bodyOnly returns true if the Body is the only property of the Alert that is set.
+func (a *Alert) bodyOnly() bool {
+	withoutBody := *a
+	withoutBody.Body = ""
+	return a.Body != "" && withoutBody.IsEmpty()
+}
@@ -2,8 +2,11 @@ package goapns
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"net/http"
+	"sync"
+	"time"
 
 	"encoding/json"
 	"fmt"
@@ -21,6 +24,64 @@ type Connection struct {
 	Certificate tls.Certificate
 	//Host is the host to which the request is sent to.
 	Host string
+	//Token is used for token-based authentication. If it is set, a signed JWT is sent
+	//in the authorization header of every request. It is constructed for you in
+	//NewTokenConnection(pathname, keyID, teamID). Certificate is empty in this case.
+	Token *Token
+	//RetryPolicy specifies if failed notifications are sent again. It is nil by default
+	//which means that every notification is sent only once. See NewRetryPolicy().
+	RetryPolicy *RetryPolicy
+	//TokenStore is consulted before a notification is sent and updated with the result.
+	//Invalid tokens are skipped with ErrorTokenInvalidated and tokens that Apple reports
+	//as Unregistered or BadDeviceToken are invalidated. It is nil by default.
+	TokenStore TokenStore
+	//Logger receives a message for every notification and for errors that are not
+	//reported in a Response. It is nil by default, which means that nothing is logged.
+	Logger Logger
+	//Metrics is informed about every request that is sent to Apples servers.
+	//It is nil by default. See PrometheusMetrics.
+	Metrics Metrics
+	//Tracer creates a Span for every request that is sent to Apples servers.
+	//It is nil by default. See the package goapnsotel for OpenTelemetry.
+	Tracer Tracer
+	//QuietHours defers notifications that would arrive during the quiet hours of a user
+	//with ErrorDeferred. It is nil by default, which means that every notification is sent right away.
+	QuietHours *QuietHoursPolicy
+	//RateLimiter limits how often notifications are sent, before they reach Apples servers.
+	//It is nil by default, which means that nothing is limited. See NewRateLimiter().
+	RateLimiter *RateLimiter
+}
+
+//Sender is implemented by Connection and ConnectionPool. It can be used wherever
+//it does not matter if notifications are sent through one or many connections.
+type Sender interface {
+	Push(message *Message, tokens []string, responseChannel chan Response)
+	Send(ctx context.Context, message *Message, token string) (Response, error)
+}
+
+//sender is implemented by types that can send an already marshaled Message,
+//so that it is marshaled only once for many tokens.
+type sender interface {
+	send(ctx context.Context, message *Message, encoded encodedMessage, token string) Response
+}
+
+//senderOf returns the sender of s if it implements one. Otherwise every
+//Message is sent with Send, which marshals it again for every token.
+func senderOf(s Sender) sender {
+	if unexported, ok := s.(sender); ok {
+		return unexported
+	}
+	return sendAdapter{s}
+}
+
+//sendAdapter sends with the exported Send method of a Sender.
+type sendAdapter struct {
+	Sender
+}
+
+func (a sendAdapter) send(ctx context.Context, message *Message, encoded encodedMessage, token string) Response {
+	response, _ := a.Send(ctx, message, token)
+	return response
 }
 
 // Apple HTTP/2 Development & Production urls
@@ -40,7 +101,6 @@ func NewConnection(pathname string, key string) (*Connection, error) {
 
 	cert, err := CertificateFromP12(pathname, key)
 	if err != nil {
-		//fmt.Printf("Error creating Connection: %v", err)
 		return nil, err
 	}
 	c.Certificate = cert
@@ -61,6 +121,31 @@ func NewConnection(pathname string, key string) (*Connection, error) {
 	return c, nil
 }
 
+//NewTokenConnection creates a new Connection object that uses token-based authentication.
+//Instead of a certificate, you specify the path to a .p8 auth key file, its Key ID
+//and your Team ID. The JWT that is sent to Apples servers is signed with ES256 and
+//refreshed automatically before it expires.
+//The default host is the development host. connection.Production() if you want to
+//use the production environment.
+//It will return a *Connection or an error. One of this is always nil.
+func NewTokenConnection(pathname string, keyID string, teamID string) (*Connection, error) {
+	c := &Connection{}
+
+	authKey, err := AuthKeyFromP8(pathname)
+	if err != nil {
+		return nil, err
+	}
+	c.Token = NewToken(authKey, keyID, teamID)
+
+	transport := &http2.Transport{TLSClientConfig: &tls.Config{}}
+
+	c.HTTPClient = http.Client{Transport: transport}
+	//Default Host is Development Host.
+	c.Host = HostDevelopment
+
+	return c, nil
+}
+
 //Development sets the host to Apples development environment.
 //Use this while your app is in development and not published.
 //This host is set by default.
@@ -81,92 +166,318 @@ func (c *Connection) Production() *Connection {
 //The result of a request is pushed into the responseChannel as an Response object.
 //As the network operation is performed asynchronously (using go keyword)
 //the method will return immediately. Use responseChannel to watch the results.
-//You will get one Response object for every request that is sent (one request per token).
+//You will get one Response object for every request that is sent (one request per token),
+//even if the Message could not be marshaled or the request could not be created.
+//The responseChannel is closed after the last Response was delivered.
 func (c *Connection) Push(message *Message, tokens []string, responseChannel chan Response) {
-	// fmt.Printf("Will push to tokens %v , URL: %v\n", tokens, c.Host)
-	dataToSend, err := json.Marshal(message)
+	pushAndClose(context.Background(), c, responseChannel, pushBatch{message: message, tokens: tokens})
+}
 
-	if err != nil {
-		fmt.Printf("Error JSONING the request: %v\naborting\n", err)
+//PushContext behaves like Push but sends every request with the context.
+//Cancelling it aborts the requests that are not done yet and a Tracer
+//creates the spans of the requests as children of the span in the context.
+func (c *Connection) PushContext(ctx context.Context, message *Message, tokens []string, responseChannel chan Response) {
+	pushAndClose(ctx, c, responseChannel, pushBatch{message: message, tokens: tokens})
+}
+
+//pushBatch is a Message and the tokens it is pushed to. If err is set,
+//nothing is sent and every token gets a Response with the error.
+type pushBatch struct {
+	message *Message
+	tokens  []string
+	err     error
+}
+
+//pushAndClose pushes every batch and closes the responseChannel
+//once every Response was delivered.
+func pushAndClose(ctx context.Context, s sender, responseChannel chan Response, batches ...pushBatch) {
+	var pending sync.WaitGroup
+	for _, batch := range batches {
+		push(ctx, s, batch, responseChannel, &pending)
+	}
+
+	//Closing the channel only after every goroutine has delivered its Response.
+	go func() {
+		pending.Wait()
 		close(responseChannel)
-		return
+	}()
+}
+
+//push starts one goroutine per token of the batch that sends the Message and delivers its Response
+//into the responseChannel. Every goroutine is tracked in pending so that the caller
+//knows when it is safe to close the channel.
+func push(ctx context.Context, s sender, batch pushBatch, responseChannel chan Response, pending *sync.WaitGroup) {
+	message, err := batch.message, batch.err
+	//If the Message can not be marshaled, the error is delivered in the Response of every token.
+	var encoded encodedMessage
+	if err == nil {
+		encoded, err = message.encode()
+	}
+
+	pending.Add(len(batch.tokens))
+	for _, token := range batch.tokens {
+		go func(token string) {
+			defer pending.Done()
+
+			if err != nil {
+				responseChannel <- newErrorResponse(message, token, err)
+				return
+			}
+			responseChannel <- s.send(ctx, message, encoded, token)
+		}(token)
+	}
+}
+
+//Send sends the Message to a single device token and waits for the result.
+//In contrast to Push, it blocks until Apples servers responded or the context
+//is done. Cancelling the context or exceeding its deadline aborts the request.
+//The returned error is the same as Response.Error, so it is nil if the
+//notification was sent successfully.
+func (c *Connection) Send(ctx context.Context, message *Message, token string) (Response, error) {
+	encoded, err := message.encode()
+	if err != nil {
+		return newErrorResponse(message, token, err), err
+	}
+
+	response := c.send(ctx, message, encoded, token)
+	return response, response.Error
+}
+
+//send delivers the already marshaled message to the given token.
+//It is the common path of Push and Send and always returns a Response,
+//errors are reported in its Error property. If the Connection has a RetryPolicy,
+//failed requests are repeated as long as the policy allows it.
+func (c *Connection) send(ctx context.Context, message *Message, encoded encodedMessage, token string) Response {
+	//Rejecting invalid notifications before they are sent, Apple would do the same.
+	if err := message.validate(encoded.dataToSend); err != nil {
+		return newErrorResponse(message, token, err)
+	}
+	if c.TokenStore != nil {
+		//If the store can not be read, the notification is sent anyway.
+		record, found, err := c.TokenStore.Lookup(token)
+		if err != nil {
+			c.logger().Error("reading the token store failed", "token", token, "error", err)
+		}
+		if err == nil && found && !record.Valid() {
+			c.logger().Debug("skipping invalidated token", "token", token, "reason", record.Reason)
+			return newErrorResponse(message, token, ErrorTokenInvalidated)
+		}
+	}
+	if err := ValidateToken(token); err != nil {
+		//A malformed token never becomes valid, it is pruned like Apple would report it.
+		response := newErrorResponse(message, token, err)
+		c.prune(response)
+		return response
 	}
 
-	for index, token := range tokens {
+	decision, until := c.QuietHours.decide(message, token)
+	switch decision {
+	case quietHoursDefer:
+		return c.deferUntil(message, token, until)
+	case quietHoursDrop:
+		response := newErrorResponse(message, token, ErrorQuietHoursExpired)
+		c.logger().Debug("dropping notification that expires in quiet hours", responseFields(response)...)
+		return response
+	case quietHoursDowngrade:
+		message = downgrade(message)
+	}
+
+	limited, err := c.RateLimiter.wait(ctx, message, token)
+	if err != nil {
+		response := newErrorResponse(message, token, err)
+		response.RateLimit = limited
+		c.logger().Debug("rate limit stopped notification", responseFields(response)...)
+		return response
+	}
+
+	attempts := 1
+	response := c.sendOnce(ctx, message, encoded.dataToSend, token)
+
+	for c.RetryPolicy.retryable(response, attempts) {
+		if !c.RetryPolicy.wait(ctx, attempts) {
+			break
+		}
+		response.Attempts = attempts
+		c.logger().Debug("retrying notification", responseFields(response)...)
+		c.metrics().Retried()
+		attempts++
+		response = c.sendOnce(ctx, message, encoded.dataToSend, token)
+	}
 
-		url := fmt.Sprintf("%v/3/device/%v", c.Host, token)
-		request, err := http.NewRequest("POST", url, bytes.NewBuffer(dataToSend))
+	response.Attempts = attempts
+	response.Truncated = encoded.truncated
+	response.RateLimit = limited
+	c.log(response)
+	c.prune(response)
+	return response
+}
+
+//deferUntil creates the Response of a notification that is deferred until the quiet hours end
+//and schedules it if the QuietHoursPolicy has a Scheduler.
+func (c *Connection) deferUntil(message *Message, token string, until time.Time) Response {
+	response := newErrorResponse(message, token, ErrorDeferred)
+	response.DeferredUntil = until
+
+	if scheduler := c.QuietHours.Scheduler; scheduler != nil {
+		id, err := scheduler.At(message, until, token)
 		if err != nil {
-			fmt.Printf("Error creating request: %v\naborting\n", err)
-			response := Response{}
 			response.Error = err
-			response.Message = message
-			responseChannel <- response
-			continue
+			c.logger().Error("scheduling deferred notification failed", responseFields(response)...)
+			return response
 		}
+		response.ScheduleID = id
+	}
+	c.logger().Debug("deferring notification in quiet hours", responseFields(response)...)
+	return response
+}
 
-		configureHeader(request, message)
-		push := func(token string, responseChannel chan Response, shouldCloseChannelWhenDone bool) {
-			if shouldCloseChannelWhenDone {
-				defer close(responseChannel)
-			}
+//log writes the result of the Response to the Logger.
+func (c *Connection) log(response Response) {
+	switch {
+	case response.Sent():
+		c.logger().Debug("notification sent", responseFields(response)...)
+	case response.NetworkError():
+		c.logger().Error("notification could not be sent", responseFields(response)...)
+	default:
+		c.logger().Warn("notification was rejected", responseFields(response)...)
+	}
+}
 
-			httpResponse, err := c.HTTPClient.Do(request)
-			if httpResponse != nil {
-				defer httpResponse.Body.Close()
-			}
+//logger returns the Logger of the Connection or one that discards everything.
+func (c *Connection) logger() Logger {
+	if c.Logger == nil {
+		return nopLogger{}
+	}
+	return c.Logger
+}
 
-			if err != nil {
-				fmt.Printf("Error during response: %v\nAborting.\n", err)
-
-				response := Response{}
-				response.Error = err
-				response.Message = message
-				response.Token = token
-				responseChannel <- response
-			} else {
-
-				//Response object that will be populated and passed into the responseChannel
-				var response Response
-
-				if httpResponse.StatusCode != http.StatusOK {
-					//Something went wrong, creating new Response object from the JSON response
-					errParsingJSON := json.NewDecoder(httpResponse.Body).Decode(&response)
-
-					if errParsingJSON != nil {
-						//We could not parse the response into JSON, we need to pass the received error into the responseChannel
-						response.Error = err
-
-					} else {
-						//We have parsed the error and populated a new Response object with it.
-
-						//Converting the JSON body (string) into an error object
-						knownError, found := errorReason[response.Reason]
-
-						if !found {
-							//We could not find the error in our map so we try to use the HTTP status code to produce some meaningful error object
-							knownError, found = errorStatus[httpResponse.StatusCode]
-
-							if !found {
-								//Could not find the error anywhere :(
-								knownError = ErrorUnknown
-							}
-						}
-						response.Error = knownError
-					}
-				}
-
-				response.Message = message
-				response.Token = token
-				response.StatusCode = httpResponse.StatusCode
-				responseChannel <- response
-			}
+//tracer returns the Tracer of the Connection or one that discards everything.
+func (c *Connection) tracer() Tracer {
+	if c.Tracer == nil {
+		return nopTracer{}
+	}
+	return c.Tracer
+}
+
+//metrics returns the Metrics of the Connection or ones that discard everything.
+func (c *Connection) metrics() Metrics {
+	if c.Metrics == nil {
+		return nopMetrics{}
+	}
+	return c.Metrics
+}
+
+//prune updates the TokenStore with the result of the Response if there is one.
+func (c *Connection) prune(response Response) {
+	if c.TokenStore == nil {
+		return
+	}
+	//The notification was already sent, a failing store must not change its Response.
+	if err := prune(c.TokenStore, response); err != nil {
+		c.logger().Error("updating the token store failed", "token", response.Token, "error", err)
+	}
+}
+
+//sendOnce performs exactly one request with the already marshaled message to the given token.
+func (c *Connection) sendOnce(ctx context.Context, message *Message, dataToSend []byte, token string) Response {
+	//Response object that will be populated and returned
+	var response Response
+	response.Message = message
+	response.Token = token
+	response.APNSID = message.Header.APNSID
+
+	ctx, span := startSpan(ctx, c.tracer(), message, token)
+	defer func() {
+		endSpan(span, response)
+	}()
+
+	url := fmt.Sprintf("%v/3/device/%v", c.Host, token)
+	request, err := http.NewRequest("POST", url, bytes.NewBuffer(dataToSend))
+	if err != nil {
+		response.Error = err
+		return response
+	}
+	request = request.WithContext(ctx)
+
+	configureHeader(request, message)
+	if err := c.authorize(request); err != nil {
+		response.Error = err
+		return response
+	}
+
+	metrics := c.metrics()
+	metrics.RequestStarted()
+	started := time.Now()
+	defer func() {
+		metrics.RequestFinished(response.StatusCode, response.Reason, time.Since(started))
+	}()
+
+	httpResponse, err := c.HTTPClient.Do(request)
+	if httpResponse != nil {
+		defer httpResponse.Body.Close()
+	}
+
+	if err != nil {
+		//Prefer the reason of the context so that callers can compare against
+		//context.Canceled and context.DeadlineExceeded.
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		} else {
+			response.networkError = true
 		}
-		shouldCloseChannelWhenDone := index == (len(tokens) - 1)
-		go push(token, responseChannel, shouldCloseChannelWhenDone)
+		response.Error = err
+		return response
+	}
+
+	if id := httpResponse.Header.Get("apns-id"); id != "" {
+		response.APNSID = id
+	}
+	if httpResponse.StatusCode != http.StatusOK {
+		//Something went wrong, populating the Response object from the JSON response
+		response.Error = errorFromResponse(httpResponse, &response)
+	}
+
+	response.StatusCode = httpResponse.StatusCode
+	return response
+}
+
+//errorFromResponse decodes the JSON body of a failed request into the Response
+//and maps the reason (or the HTTP status code as fallback) to one of the known errors.
+func errorFromResponse(httpResponse *http.Response, response *Response) error {
+	errParsingJSON := json.NewDecoder(httpResponse.Body).Decode(response)
+
+	if errParsingJSON != nil {
+		//We could not parse the response into JSON, try the status code instead
+		response.Reason = ""
+	}
 
+	//Converting the JSON body (string) into an error object
+	knownError, found := errorReason[response.Reason]
+
+	if !found {
+		//We could not find the error in our map so we try to use the HTTP status code to produce some meaningful error object
+		knownError, found = errorStatus[httpResponse.StatusCode]
+
+		if !found {
+			//Could not find the error anywhere :(
+			knownError = ErrorUnknown
+		}
 	}
+	return knownError
+}
 
+//authorize sets the authorization header of the request if the Connection
+//uses token-based authentication. Certificate-based connections are left untouched.
+func (c *Connection) authorize(request *http.Request) error {
+	if c.Token == nil {
+		return nil
+	}
+	bearer, err := c.Token.Bearer()
+	if err != nil {
+		return err
+	}
+	request.Header.Set("authorization", "bearer "+bearer)
+	return nil
 }
 
 //configureHader takes a Message and a htto.Request. It sets the header properties
@@ -182,14 +493,18 @@ func configureHeader(request *http.Request, message *Message) {
 	if !message.Header.Expiration.IsZero() {
 		request.Header.Set("apns-expiration", fmt.Sprintf("%v", message.Header.Expiration.Unix()))
 	}
-	//Only set the priority if it is low because high is the default
-	//value that is assumed when no priority is specified.
+	//Only set the priority if it is not high because high is the default
+	//value that is assumed when no priority is specified. 0 means that it is not set.
 	//We want to omit everything we can to save bandwith.
-	if message.Header.Priority == PriorityLow {
+	if message.Header.Priority != PriorityHigh && message.Header.Priority != 0 {
 		request.Header.Set("apns-priority", fmt.Sprintf("%v", message.Header.Priority))
 	}
-	if message.Header.Topic != "" {
-		request.Header.Set("apns-topic", message.Header.Topic)
+	//The push type is inferred from the content if it is not set and some
+	//push types require a suffix on the topic.
+	pushType := message.InferredPushType()
+	request.Header.Set("apns-push-type", string(pushType))
+	if topic := message.Header.topic(pushType); topic != "" {
+		request.Header.Set("apns-topic", topic)
 	}
 	if message.Header.CollapseID != "" {
 		request.Header.Set("apns-collapse-id", message.Header.CollapseID)
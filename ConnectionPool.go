@@ -0,0 +1,271 @@
+package goapns
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//ErrorPoolSize is returned by NewConnectionPool if the size is not positive.
+var ErrorPoolSize = errors.New("The size of a connection pool must be at least 1.")
+
+//DefaultPoolMaxFailures is the number of consecutive network errors after which
+//a Connection of a ConnectionPool is considered dead and replaced.
+const DefaultPoolMaxFailures = 3
+
+//ConnectionPool spreads notifications over multiple Connections.
+//Every Connection uses its own HTTP/2 transport and therefore its own TCP connection
+//to Apples servers, so the throughput is not capped by the number of concurrent
+//streams that Apple allows on one connection.
+//
+//Every request is sent through the Connection with the fewest requests in flight.
+//A Connection that fails with MaxFailures network errors in a row is replaced
+//by a new one.
+type ConnectionPool struct {
+	//MaxFailures is the number of consecutive network errors after which a Connection
+	//is replaced. It is set to DefaultPoolMaxFailures by NewConnectionPool.
+	MaxFailures int
+
+	newConnection func() (*Connection, error)
+
+	mutex   sync.Mutex
+	members []*pooledConnection
+	host    string
+	next    int
+}
+
+//pooledConnection is a Connection of a ConnectionPool together with its statistics.
+type pooledConnection struct {
+	//connection, replacements, since, lastError, failures and replacing are guarded by the pool mutex.
+	connection   *Connection
+	replacements int
+	since        time.Time
+	lastError    error
+	failures     int
+	//replacing is true while a replacement for the Connection is created.
+	replacing bool
+
+	inFlight int64
+	sent     uint64
+	failed   uint64
+}
+
+//ConnectionStats describes the state of one Connection of a ConnectionPool.
+type ConnectionStats struct {
+	//Index is the position of the Connection in the pool.
+	Index int
+	//Host is the host to which the Connection sends its requests.
+	Host string
+	//InFlight is the number of requests that currently wait for a response.
+	InFlight int
+	//Sent is the number of notifications that were sent successfully.
+	Sent uint64
+	//Failed is the number of notifications that could not be sent.
+	Failed uint64
+	//Replacements is the number of times the Connection was replaced because it was dead.
+	Replacements int
+	//Since is the time at which the current Connection was opened.
+	Since time.Time
+	//LastError is the last error that occurred, nil if there was none.
+	LastError error
+}
+
+//NewConnectionPool creates a ConnectionPool with size Connections.
+//newConnection is called to open every Connection and to replace dead ones, for example:
+//
+//	pool, err := goapns.NewConnectionPool(4, func() (*goapns.Connection, error) {
+//		return goapns.NewConnection(pathname, key)
+//	})
+//
+//It will return a *ConnectionPool or an error. One of this is always nil.
+func NewConnectionPool(size int, newConnection func() (*Connection, error)) (*ConnectionPool, error) {
+	if size < 1 {
+		return nil, ErrorPoolSize
+	}
+
+	p := &ConnectionPool{MaxFailures: DefaultPoolMaxFailures, newConnection: newConnection}
+	for i := 0; i < size; i++ {
+		connection, err := newConnection()
+		if err != nil {
+			return nil, err
+		}
+		p.members = append(p.members, &pooledConnection{connection: connection, since: time.Now()})
+	}
+	return p, nil
+}
+
+//Development sets the host of every Connection to Apples development environment.
+func (p *ConnectionPool) Development() *ConnectionPool {
+	return p.setHost(HostDevelopment)
+}
+
+//Production sets the host of every Connection to Apples production environment.
+//Connections that replace dead ones use this host as well.
+func (p *ConnectionPool) Production() *ConnectionPool {
+	return p.setHost(HostProduction)
+}
+
+func (p *ConnectionPool) setHost(host string) *ConnectionPool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.host = host
+	for _, member := range p.members {
+		//Requests in flight read the Host, so the Connection is replaced by a copy instead of changed.
+		moved := *member.connection
+		moved.Host = host
+		member.connection = &moved
+	}
+	return p
+}
+
+//Size returns the number of Connections in the pool.
+func (p *ConnectionPool) Size() int {
+	return len(p.members)
+}
+
+//Stats returns the current statistics of every Connection in the pool.
+func (p *ConnectionPool) Stats() []ConnectionStats {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	stats := make([]ConnectionStats, len(p.members))
+	for i, member := range p.members {
+		stats[i] = ConnectionStats{
+			Index:        i,
+			Host:         member.connection.Host,
+			InFlight:     int(atomic.LoadInt64(&member.inFlight)),
+			Sent:         atomic.LoadUint64(&member.sent),
+			Failed:       atomic.LoadUint64(&member.failed),
+			Replacements: member.replacements,
+			Since:        member.since,
+			LastError:    member.lastError,
+		}
+	}
+	return stats
+}
+
+//Push sends the Message to every token, spread over the Connections of the pool.
+//It behaves like Connection.Push: you get one Response per token in the responseChannel
+//which is closed after the last Response was delivered.
+func (p *ConnectionPool) Push(message *Message, tokens []string, responseChannel chan Response) {
+	pushAndClose(context.Background(), p, responseChannel, pushBatch{message: message, tokens: tokens})
+}
+
+//PushContext behaves like Push but sends every request with the context, like Connection.PushContext.
+func (p *ConnectionPool) PushContext(ctx context.Context, message *Message, tokens []string, responseChannel chan Response) {
+	pushAndClose(ctx, p, responseChannel, pushBatch{message: message, tokens: tokens})
+}
+
+//Send sends the Message to a single device token through the least busy Connection
+//and waits for the result. It behaves like Connection.Send.
+func (p *ConnectionPool) Send(ctx context.Context, message *Message, token string) (Response, error) {
+	encoded, err := message.encode()
+	if err != nil {
+		return newErrorResponse(message, token, err), err
+	}
+
+	response := p.send(ctx, message, encoded, token)
+	return response, response.Error
+}
+
+func (p *ConnectionPool) send(ctx context.Context, message *Message, encoded encodedMessage, token string) Response {
+	member, connection := p.pick()
+
+	atomic.AddInt64(&member.inFlight, 1)
+	response := connection.send(ctx, message, encoded, token)
+	atomic.AddInt64(&member.inFlight, -1)
+
+	if response.Sent() {
+		atomic.AddUint64(&member.sent, 1)
+	} else {
+		atomic.AddUint64(&member.failed, 1)
+	}
+	p.record(member, connection, response)
+
+	return response
+}
+
+//pick returns the member with the fewest requests in flight. Members with the same
+//number are chosen round robin.
+func (p *ConnectionPool) pick() (*pooledConnection, *Connection) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	var picked *pooledConnection
+	for i := range p.members {
+		member := p.members[(p.next+i)%len(p.members)]
+		if picked == nil || atomic.LoadInt64(&member.inFlight) < atomic.LoadInt64(&picked.inFlight) {
+			picked = member
+		}
+	}
+	p.next = (p.next + 1) % len(p.members)
+
+	return picked, picked.connection
+}
+
+//record updates the failure count of the member and replaces its Connection
+//if it failed with too many network errors in a row.
+func (p *ConnectionPool) record(member *pooledConnection, connection *Connection, response Response) {
+	p.mutex.Lock()
+
+	if response.Error != nil {
+		member.lastError = response.Error
+	}
+
+	//The response belongs to a Connection that was already replaced.
+	if member.connection != connection {
+		p.mutex.Unlock()
+		return
+	}
+
+	if !response.NetworkError() {
+		member.failures = 0
+		p.mutex.Unlock()
+		return
+	}
+
+	member.failures++
+	if member.failures < p.MaxFailures || member.replacing {
+		p.mutex.Unlock()
+		return
+	}
+	member.replacing = true
+	p.mutex.Unlock()
+
+	//Creating a Connection reads the certificate, the other requests must not wait for it.
+	replacement, err := p.newConnection()
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	member.replacing = false
+
+	if err != nil {
+		//Keep the old Connection, maybe it recovers. We try again on the next error.
+		member.lastError = err
+		return
+	}
+	if member.connection != connection {
+		//Someone else replaced it in the meantime.
+		replacement.HTTPClient.CloseIdleConnections()
+		return
+	}
+	//The replacement keeps the configuration of the Connection it replaces,
+	//only the HTTP client and the credentials are new.
+	configured := *connection
+	configured.HTTPClient = replacement.HTTPClient
+	configured.Certificate = replacement.Certificate
+	configured.Token = replacement.Token
+	configured.Host = replacement.Host
+	if p.host != "" {
+		configured.Host = p.host
+	}
+	connection.HTTPClient.CloseIdleConnections()
+
+	member.connection = &configured
+	member.replacements++
+	member.since = time.Now()
+	member.failures = 0
+}
@@ -0,0 +1,166 @@
+package goapns_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tantalum73/Go-APNS"
+)
+
+func TestConnectionPoolSize(t *testing.T) {
+	pool, err := goapns.NewConnectionPool(0, func() (*goapns.Connection, error) {
+		return mockConnection(t), nil
+	})
+	assert.Equal(t, goapns.ErrorPoolSize, err)
+	assert.Nil(t, pool)
+}
+
+func TestConnectionPoolPush(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	pool, err := goapns.NewConnectionPool(3, func() (*goapns.Connection, error) {
+		conn := mockConnection(t)
+		conn.Host = server.URL
+		return conn, nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, pool.Size())
+
+	tokens := testTokens(9)
+	channel := make(chan goapns.Response)
+	pool.Push(mockMessage(), tokens, channel)
+
+	received := 0
+	for response := range channel {
+		assert.True(t, response.Sent())
+		received++
+	}
+	assert.Equal(t, len(tokens), received)
+
+	var sent uint64
+	for _, stats := range pool.Stats() {
+		assert.Equal(t, server.URL, stats.Host)
+		assert.Equal(t, 0, stats.InFlight)
+		assert.Equal(t, uint64(0), stats.Failed)
+		assert.True(t, stats.Sent > 0, "requests are not spread over the pool")
+		sent += stats.Sent
+	}
+	assert.Equal(t, uint64(len(tokens)), sent)
+}
+
+func TestConnectionPoolReplacesDeadConnection(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	dead := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	dead.Close()
+
+	logger := &recordingLogger{}
+	opened := 0
+	pool, err := goapns.NewConnectionPool(1, func() (*goapns.Connection, error) {
+		conn := mockConnection(t)
+		conn.Host = server.URL
+		if opened == 0 {
+			conn.Host = dead.URL
+			conn.Logger = logger
+		}
+		opened++
+		return conn, nil
+	})
+	assert.Nil(t, err)
+	pool.MaxFailures = 2
+
+	for i := 0; i < pool.MaxFailures; i++ {
+		response, err := pool.Send(context.Background(), mockMessage(), testToken)
+		assert.Error(t, err)
+		assert.True(t, response.NetworkError())
+	}
+
+	response, err := pool.Send(context.Background(), mockMessage(), testToken)
+	assert.Nil(t, err)
+	assert.True(t, response.Sent())
+	//The replacement logs like the Connection it replaced.
+	assert.Equal(t, "debug", logger.entries[len(logger.entries)-1].level)
+
+	stats := pool.Stats()[0]
+	assert.Equal(t, 1, stats.Replacements)
+	assert.Equal(t, uint64(1), stats.Sent)
+	assert.Equal(t, uint64(2), stats.Failed)
+	assert.Equal(t, server.URL, stats.Host)
+}
+
+func TestConnectionPoolHostWhileSending(t *testing.T) {
+	arrived := make(chan struct{})
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		arrived <- struct{}{}
+		<-release
+	}))
+	defer server.Close()
+
+	pool, err := goapns.NewConnectionPool(1, func() (*goapns.Connection, error) {
+		conn := mockConnection(t)
+		conn.Host = server.URL
+		return conn, nil
+	})
+	assert.Nil(t, err)
+
+	sent := make(chan goapns.Response)
+	go func() {
+		response, _ := pool.Send(context.Background(), mockMessage(), testToken)
+		sent <- response
+	}()
+	<-arrived
+
+	//The request in flight is not affected by the new host.
+	pool.Production()
+	close(release)
+	response := <-sent
+	assert.True(t, response.Sent())
+	assert.Equal(t, goapns.HostProduction, pool.Stats()[0].Host)
+}
+
+func TestConnectionPoolReplacesWithoutBlocking(t *testing.T) {
+	dead := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	dead.Close()
+
+	creating := make(chan struct{})
+	release := make(chan struct{})
+	opened := 0
+	pool, err := goapns.NewConnectionPool(1, func() (*goapns.Connection, error) {
+		conn := mockConnection(t)
+		conn.Host = dead.URL
+		if opened > 0 {
+			close(creating)
+			<-release
+		}
+		opened++
+		return conn, nil
+	})
+	assert.Nil(t, err)
+	pool.MaxFailures = 1
+
+	sent := make(chan struct{})
+	go func() {
+		pool.Send(context.Background(), mockMessage(), testToken)
+		close(sent)
+	}()
+	<-creating
+
+	//The pool is usable while the replacement is created.
+	stats := make(chan []goapns.ConnectionStats)
+	go func() { stats <- pool.Stats() }()
+	select {
+	case <-stats:
+	case <-time.After(time.Second):
+		t.Fatal("Stats blocked while a Connection was replaced")
+	}
+
+	close(release)
+	<-sent
+	assert.Equal(t, 1, pool.Stats()[0].Replacements)
+}
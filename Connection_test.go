@@ -1,6 +1,7 @@
 package goapns_test
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -11,6 +12,21 @@ import (
 	"github.com/tantalum73/Go-APNS"
 )
 
+//Device tokens of the tests, they have 32 bytes like the tokens of Apple.
+const (
+	testToken      = "1234567890abcdef1234567890abcdef1234567890abcdef1234567890abcdef"
+	otherTestToken = "0987654321fedcba0987654321fedcba0987654321fedcba0987654321fedcba"
+)
+
+//testTokens returns n different device tokens.
+func testTokens(n int) []string {
+	tokens := make([]string, n)
+	for i := range tokens {
+		tokens[i] = fmt.Sprintf("%064x", i)
+	}
+	return tokens
+}
+
 func mockMessage() *goapns.Message {
 	m := goapns.NewMessage().Badge(42).Title("title").Body("body")
 	return m
@@ -26,6 +42,22 @@ func mockConnection(t *testing.T) *goapns.Connection {
 	conn.HTTPClient = client
 	return conn
 }
+
+//recordingConnection returns a mocked Connection to a server that answers every request with 200
+//and records it on the returned channel. Requests that do not fit into the channel are not recorded.
+func recordingConnection(t *testing.T) (*goapns.Connection, chan *http.Request, func()) {
+	conn := mockConnection(t)
+	received := make(chan *http.Request, 64)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case received <- r:
+		default:
+		}
+	}))
+	conn.Host = server.URL
+	return conn, received, server.Close
+}
+
 func TestConnectionCertificateWrongPath(t *testing.T) {
 	pathToCert := "example/nowhere"
 	conn, err := goapns.NewConnection(pathToCert, "wrongPassword")
@@ -47,7 +79,7 @@ func TestConnectionCertificateCorrectPassphrase(t *testing.T) {
 func TestConnectionToken(t *testing.T) {
 	conn := mockConnection(t)
 
-	token := []string{"1234567890"}
+	token := []string{testToken}
 
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		assert.Equal(t, "POST", r.Method)
@@ -67,7 +99,7 @@ func TestConnectionToken(t *testing.T) {
 func TestConnectionHeaderDefaults(t *testing.T) {
 	conn := mockConnection(t)
 
-	token := []string{"1234567890"}
+	token := []string{testToken}
 
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		assert.Equal(t, "application/json; charset=utf-8", r.Header.Get("Content-Type"))
@@ -89,8 +121,8 @@ func TestConnectionHeader(t *testing.T) {
 
 	collapseID := "com.example.euroApp.scroreChanged"
 
-	token := []string{"1234567890"}
-	message := mockMessage().APNSID("102").PriorityHigh().Topic("topic").Expiration(time.Now()).CollapseID(collapseID)
+	token := []string{testToken}
+	message := mockMessage().APNSID("123e4567-e89b-12d3-a456-426655440000").PriorityHigh().Topic("topic").Expiration(time.Now()).CollapseID(collapseID)
 
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		assert.Equal(t, message.Header.APNSID, r.Header.Get("apns-id"))
@@ -110,8 +142,8 @@ func TestConnectionHeader(t *testing.T) {
 func TestConnectionTokenExpired(t *testing.T) {
 	conn := mockConnection(t)
 
-	token := []string{"12345678912"}
-	message := mockMessage().APNSID("102").PriorityHigh().Topic("topic").Expiration(time.Now())
+	token := []string{testToken}
+	message := mockMessage().APNSID("123e4567-e89b-12d3-a456-426655440000").PriorityHigh().Topic("topic").Expiration(time.Now())
 	expired := time.Now().UTC().Unix()
 
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -139,8 +171,8 @@ func TestConnectionTokenExpired(t *testing.T) {
 func TestConnectionBadPriority(t *testing.T) {
 	conn := mockConnection(t)
 
-	token := []string{"12345678912"}
-	message := mockMessage().APNSID("102")
+	token := []string{testToken}
+	message := mockMessage().APNSID("123e4567-e89b-12d3-a456-426655440000")
 
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
@@ -159,3 +191,173 @@ func TestConnectionBadPriority(t *testing.T) {
 
 	}
 }
+
+func TestConnectionSend(t *testing.T) {
+	conn := mockConnection(t)
+
+	token := testToken
+	message := mockMessage().Topic("topic")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, fmt.Sprintf("/3/device/%s", token), r.URL.String())
+		assert.Equal(t, message.Header.Topic, r.Header.Get("apns-topic"))
+	}))
+	defer server.Close()
+
+	conn.Host = server.URL
+	response, err := conn.Send(context.Background(), message, token)
+	assert.Nil(t, err)
+	assert.True(t, response.Sent())
+	assert.Equal(t, token, response.Token)
+	assert.Equal(t, message, response.Message)
+}
+
+func TestConnectionSendError(t *testing.T) {
+	conn := mockConnection(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"reason": "BadDeviceToken"}`))
+	}))
+	defer server.Close()
+
+	conn.Host = server.URL
+	response, err := conn.Send(context.Background(), mockMessage(), testToken)
+	assert.Equal(t, goapns.ErrorBadDeviceToken, err)
+	assert.Equal(t, goapns.ErrorBadDeviceToken, response.Error)
+	assert.Equal(t, "BadDeviceToken", response.Reason)
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+	assert.False(t, response.Sent())
+}
+
+func TestConnectionSendDeadline(t *testing.T) {
+	conn := mockConnection(t)
+
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	conn.Host = server.URL
+	response, err := conn.Send(ctx, mockMessage(), testToken)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.False(t, response.Sent())
+}
+
+func TestConnectionPushDeliversEveryResponse(t *testing.T) {
+	conn := mockConnection(t)
+	tokens := testTokens(10)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		//The last token answers first, the channel must stay open for the others.
+		if r.URL.String() != "/3/device/"+tokens[9] {
+			time.Sleep(20 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+
+	channel := make(chan goapns.Response)
+
+	conn.Host = server.URL
+	conn.Push(mockMessage(), tokens, channel)
+
+	received := 0
+	for response := range channel {
+		assert.True(t, response.Sent())
+		received++
+	}
+	assert.Equal(t, len(tokens), received)
+}
+
+func TestConnectionPushMarshalError(t *testing.T) {
+	conn := mockConnection(t)
+
+	tokens := []string{testToken, otherTestToken}
+	message := mockMessage().Custom("key", make(chan int))
+	channel := make(chan goapns.Response, len(tokens))
+
+	conn.Push(message, tokens, channel)
+
+	received := 0
+	for response := range channel {
+		assert.Error(t, response.Error)
+		assert.False(t, response.Sent())
+		assert.Contains(t, tokens, response.Token)
+		received++
+	}
+	assert.Equal(t, len(tokens), received)
+}
+
+func TestConnectionPushNoTokens(t *testing.T) {
+	conn := mockConnection(t)
+
+	channel := make(chan goapns.Response)
+	conn.Push(mockMessage(), []string{}, channel)
+
+	_, open := <-channel
+	assert.False(t, open)
+}
+
+func TestConnectionSendInvalidPayload(t *testing.T) {
+	conn := mockConnection(t)
+
+	response, err := conn.Send(context.Background(), mockMessage().RelevanceScore(2), testToken)
+	assert.Equal(t, goapns.ErrorInvalidRelevanceScore, err)
+	assert.False(t, response.Sent())
+}
+
+func TestConnectionPushTypeHeader(t *testing.T) {
+	conn := mockConnection(t)
+
+	message := goapns.NewMessage().Topic("com.example.app").PushType(goapns.PushTypeVoIP).Custom("caller", "Alice")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "voip", r.Header.Get("apns-push-type"))
+		assert.Equal(t, "com.example.app.voip", r.Header.Get("apns-topic"))
+	}))
+	defer server.Close()
+
+	conn.Host = server.URL
+	response, err := conn.Send(context.Background(), message, testToken)
+	assert.Nil(t, err)
+	assert.True(t, response.Sent())
+
+	//Background notifications are inferred and keep the plain topic.
+	message = goapns.NewMessage().Topic("com.example.app").ContentAvailable()
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "background", r.Header.Get("apns-push-type"))
+		assert.Equal(t, "5", r.Header.Get("apns-priority"))
+		assert.Equal(t, "com.example.app", r.Header.Get("apns-topic"))
+	})
+	response, err = conn.Send(context.Background(), message, testToken)
+	assert.Nil(t, err)
+	assert.True(t, response.Sent())
+}
+
+func TestConnectionPushTypeValidation(t *testing.T) {
+	conn := mockConnection(t)
+
+	//Nothing reaches the server, every notification is rejected before.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("invalid notification was sent")
+	}))
+	defer server.Close()
+	conn.Host = server.URL
+
+	_, err := conn.Send(context.Background(), goapns.NewMessage().ContentAvailable().PriorityHigh(), testToken)
+	assert.Equal(t, goapns.ErrorPushTypePriorityMismatch, err)
+
+	_, err = conn.Send(context.Background(), mockMessage().Topic("com.example.app.voip"), testToken)
+	assert.Equal(t, goapns.ErrorPushTypeTopicMismatch, err)
+
+	_, err = conn.Send(context.Background(), mockMessage().Topic("com.example.app.voip").PushType(goapns.PushTypePushToTalk), testToken)
+	assert.Equal(t, goapns.ErrorPushTypeTopicMismatch, err)
+
+	_, err = conn.Send(context.Background(), mockMessage().PushType("carrier-pigeon"), testToken)
+	assert.Equal(t, goapns.ErrorInvalidPushType, err)
+}
@@ -0,0 +1,158 @@
+package goapns
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//ErrorInvalidCron is returned by ParseCron if the expression can not be parsed.
+var ErrorInvalidCron = errors.New("The cron expression is invalid.")
+
+//cronMacros are the shortcuts ParseCron understands.
+var cronMacros = map[string]string{
+	"@yearly":  "0 0 1 1 *",
+	"@monthly": "0 0 1 * *",
+	"@weekly":  "0 0 * * 0",
+	"@daily":   "0 0 * * *",
+	"@hourly":  "0 * * * *",
+}
+
+//cronSearchLimit is how far Next looks into the future, an expression like "0 0 30 2 *" never matches.
+const cronSearchLimit = 5 * 366 * 24 * time.Hour
+
+//Cron is a recurrence in the format of crontab: minute, hour, day of month, month and day of week.
+//Every field is a * or a comma separated list of values, ranges like 1-5 and steps like */15 or 8-18/2.
+//Day of week is 0 (Sunday) to 6, 7 is Sunday as well. Names of months and days are not supported.
+//If day of month and day of week are both restricted, a day matches if one of them does, like in cron.
+//A field that starts with *, like */2, does not count as restricted.
+//The macros @yearly, @monthly, @weekly, @daily and @hourly can be used as well.
+type Cron struct {
+	spec                                   string
+	minutes, hours, days, months, weekdays uint64
+	anyDay, anyWeekday                     bool
+}
+
+//ParseCron parses the expression, for example "30 8 * * 1-5" for 8:30 on every weekday.
+//It returns ErrorInvalidCron if it can not be parsed.
+func ParseCron(spec string) (*Cron, error) {
+	expression := strings.TrimSpace(spec)
+	if macro, found := cronMacros[expression]; found {
+		expression = macro
+	}
+
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, ErrorInvalidCron
+	}
+
+	c := &Cron{spec: spec}
+	var err error
+	if c.minutes, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, err
+	}
+	if c.hours, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, err
+	}
+	if c.days, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, err
+	}
+	if c.months, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, err
+	}
+	if c.weekdays, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, err
+	}
+	if c.weekdays&(1<<7) != 0 {
+		c.weekdays |= 1
+	}
+	c.anyDay = strings.HasPrefix(fields[2], "*")
+	c.anyWeekday = strings.HasPrefix(fields[4], "*")
+	return c, nil
+}
+
+//String returns the expression the Cron was parsed from.
+func (c *Cron) String() string {
+	return c.spec
+}
+
+//Next returns the first time after the given one that matches the Cron, in the location of after.
+//It returns the zero time if there is none within the next five years.
+func (c *Cron) Next(after time.Time) time.Time {
+	location := after.Location()
+	t := time.Date(after.Year(), after.Month(), after.Day(), after.Hour(), after.Minute(), 0, 0, location).Add(time.Minute)
+	limit := after.Add(cronSearchLimit)
+
+	for t.Before(limit) {
+		switch {
+		case c.months&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, location)
+		case !c.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, location)
+		case c.hours&(1<<uint(t.Hour())) == 0:
+			next := time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, location)
+			if !next.After(t) {
+				//The clock was set back, the hour repeats.
+				next = t.Add(time.Hour).Truncate(time.Minute)
+			}
+			t = next
+		case c.minutes&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+//matchesDay reports if the day of month or the day of week of t matches.
+func (c *Cron) matchesDay(t time.Time) bool {
+	day := c.days&(1<<uint(t.Day())) != 0
+	weekday := c.weekdays&(1<<uint(t.Weekday())) != 0
+	if c.anyDay || c.anyWeekday {
+		return day && weekday
+	}
+	return day || weekday
+}
+
+//parseCronField returns a bit set of the values of the field.
+func parseCronField(field string, min int, max int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step <= 0 {
+				return 0, ErrorInvalidCron
+			}
+			part = part[:i]
+		}
+
+		from, to := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if from, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, ErrorInvalidCron
+			}
+			to = from
+			if len(bounds) == 2 {
+				if to, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, ErrorInvalidCron
+				}
+			} else if step > 1 {
+				//5/15 means from 5 to the end in steps of 15.
+				to = max
+			}
+		}
+		if from < min || to > max || from > to {
+			return 0, ErrorInvalidCron
+		}
+
+		for value := from; value <= to; value += step {
+			set |= 1 << uint(value)
+		}
+	}
+	return set, nil
+}
@@ -0,0 +1,57 @@
+package goapns_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tantalum73/Go-APNS"
+)
+
+func TestCronNext(t *testing.T) {
+	//Wednesday
+	start := time.Date(2024, time.January, 10, 8, 30, 15, 0, time.UTC)
+
+	tests := []struct {
+		spec string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2024, time.January, 10, 8, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, time.January, 10, 8, 45, 0, 0, time.UTC)},
+		{"30 8 * * *", time.Date(2024, time.January, 11, 8, 30, 0, 0, time.UTC)},
+		{"0 9-17/4 * * *", time.Date(2024, time.January, 10, 9, 0, 0, 0, time.UTC)},
+		{"0 8 * * 6,7", time.Date(2024, time.January, 13, 8, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 15 * 5", time.Date(2024, time.January, 12, 0, 0, 0, 0, time.UTC)},
+		{"0 9 */2 * 1", time.Date(2024, time.January, 15, 9, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2024, time.January, 10, 9, 0, 0, 0, time.UTC)},
+		{"@yearly", time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, test := range tests {
+		cron, err := goapns.ParseCron(test.spec)
+		assert.Nil(t, err, test.spec)
+		assert.Equal(t, test.next, cron.Next(start), test.spec)
+	}
+}
+
+func TestCronNextLocation(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("time zone database is not available")
+	}
+	cron, _ := goapns.ParseCron("30 2 * * *")
+
+	//The clock jumps from 2:00 to 3:00 on March 31, 2024, 2:30 does not exist that day.
+	next := cron.Next(time.Date(2024, time.March, 30, 12, 0, 0, 0, berlin))
+	assert.Equal(t, time.Date(2024, time.April, 1, 2, 30, 0, 0, berlin), next)
+	assert.Equal(t, berlin, next.Location())
+}
+
+func TestCronInvalid(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8", "*/0 * * * *", "5-1 * * * *", "a * * * *", "@often"} {
+		_, err := goapns.ParseCron(spec)
+		assert.Equal(t, goapns.ErrorInvalidCron, err, spec)
+	}
+}
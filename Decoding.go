@@ -0,0 +1,139 @@
+package goapns
+
+import (
+	"encoding/json"
+	"time"
+)
+
+//apsDictionary is the aps dictionary as it is sent to Apples servers.
+//Pointers are used for values whose absence differs from their zero value.
+type apsDictionary struct {
+	Alert             json.RawMessage   `json:"alert"`
+	Badge             *int              `json:"badge"`
+	Sound             json.RawMessage   `json:"sound"`
+	ContentAvailable  int               `json:"content-available"`
+	MutableContent    int               `json:"mutable-content"`
+	Category          string            `json:"category"`
+	ThreadID          string            `json:"thread-id"`
+	InterruptionLevel InterruptionLevel `json:"interruption-level"`
+	RelevanceScore    *float64          `json:"relevance-score"`
+	TargetContentID   string            `json:"target-content-id"`
+	FilterCriteria    string            `json:"filter-criteria"`
+
+	Event          LiveActivityEvent      `json:"event"`
+	Timestamp      int64                  `json:"timestamp"`
+	ContentState   map[string]interface{} `json:"content-state"`
+	DismissalDate  int64                  `json:"dismissal-date"`
+	StaleDate      int64                  `json:"stale-date"`
+	AttributesType string                 `json:"attributes-type"`
+	Attributes     map[string]interface{} `json:"attributes"`
+}
+
+//UnmarshalJSON is the counterpart of MarshalJSON. It reads the aps dictionary into
+//Alert and Payload and every other top level key into the custom values.
+//Values that are not in the JSON keep the defaults of NewMessage. An alert that is
+//sent as plain string becomes the Body and CompactAlert is set to keep the format.
+//The Header is not part of the JSON, use an Envelope to store it as well.
+func (m *Message) UnmarshalJSON(data []byte) error {
+	var dictionary map[string]json.RawMessage
+	if err := json.Unmarshal(data, &dictionary); err != nil {
+		return err
+	}
+
+	var aps apsDictionary
+	if raw, found := dictionary["aps"]; found {
+		if err := json.Unmarshal(raw, &aps); err != nil {
+			return err
+		}
+		delete(dictionary, "aps")
+	}
+
+	decoded := NewMessage()
+	if err := decoded.apply(&aps); err != nil {
+		return err
+	}
+
+	for key, raw := range dictionary {
+		var object interface{}
+		if err := json.Unmarshal(raw, &object); err != nil {
+			return err
+		}
+		decoded.Custom(key, object)
+	}
+
+	//Keeping the Header, it is not part of the JSON. A zero Message gets the Header of NewMessage.
+	if m.Header != (Header{}) {
+		decoded.Header = m.Header
+	}
+	*m = *decoded
+	return nil
+}
+
+//apply copies the values of the aps dictionary into the Alert and Payload of the Message.
+func (m *Message) apply(aps *apsDictionary) error {
+	if len(aps.Alert) > 0 && string(aps.Alert) != "null" {
+		if aps.Alert[0] == '"' {
+			if err := json.Unmarshal(aps.Alert, &m.Alert.Body); err != nil {
+				return err
+			}
+			m.compactAlert = true
+		} else if err := json.Unmarshal(aps.Alert, &m.Alert); err != nil {
+			return err
+		}
+	}
+
+	if aps.Badge != nil {
+		m.Payload.Badge = *aps.Badge
+	}
+	if len(aps.Sound) > 0 {
+		//Regular sounds are strings, critical sounds are dictionaries.
+		if aps.Sound[0] == '"' {
+			if err := json.Unmarshal(aps.Sound, &m.Payload.Sound); err != nil {
+				return err
+			}
+		} else {
+			var sound struct {
+				Critical int     `json:"critical"`
+				Name     string  `json:"name"`
+				Volume   float64 `json:"volume"`
+			}
+			if err := json.Unmarshal(aps.Sound, &sound); err != nil {
+				return err
+			}
+			if sound.Critical != 0 {
+				m.Payload.CriticalSound = NewCriticalSound(sound.Name, sound.Volume)
+			} else {
+				m.Payload.Sound = sound.Name
+			}
+		}
+	}
+	m.Payload.RelevanceScore = aps.RelevanceScore
+	m.Payload.ContentAvailable = aps.ContentAvailable
+	m.Payload.MutableContent = aps.MutableContent
+	m.Payload.Category = aps.Category
+	m.Payload.ThreadID = aps.ThreadID
+	m.Payload.InterruptionLevel = aps.InterruptionLevel
+	m.Payload.TargetContentID = aps.TargetContentID
+	m.Payload.FilterCriteria = aps.FilterCriteria
+
+	if aps.Event != "" {
+		m.Payload.LiveActivity = &LiveActivity{
+			Event:          aps.Event,
+			Timestamp:      unixTime(aps.Timestamp),
+			ContentState:   aps.ContentState,
+			DismissalDate:  unixTime(aps.DismissalDate),
+			StaleDate:      unixTime(aps.StaleDate),
+			AttributesType: aps.AttributesType,
+			Attributes:     aps.Attributes,
+		}
+	}
+	return nil
+}
+
+//unixTime converts UNIX epoch seconds to a time.Time, 0 becomes the zero time.
+func unixTime(seconds int64) time.Time {
+	if seconds == 0 {
+		return time.Time{}
+	}
+	return time.Unix(seconds, 0)
+}
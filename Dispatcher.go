@@ -0,0 +1,139 @@
+package goapns
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+//ErrorDispatcherClosed is returned if a Message is dispatched after the Dispatcher was closed.
+var ErrorDispatcherClosed = errors.New("The dispatcher is closed and does not accept new notifications.")
+
+//Default sizes that are used by NewDispatcher if you pass a value <= 0.
+const (
+	DefaultDispatcherWorkers   = 64
+	DefaultDispatcherQueueSize = 1024
+)
+
+//Dispatcher sends notifications through a fixed number of workers.
+//In contrast to Connection.Push, which starts one goroutine per token, the number
+//of in-flight requests never exceeds the number of workers. Tokens are buffered
+//in a queue of a fixed size. If the queue is full, Dispatch blocks until a worker
+//is ready again so that producers are slowed down instead of piling up requests.
+//
+//You get one Response per token on the Responses() channel. Make sure to read from it,
+//otherwise the workers block and so does Dispatch.
+type Dispatcher struct {
+	sender    Sender
+	jobs      chan dispatchJob
+	responses chan Response
+
+	//mutex guards closed and prevents jobs from being sent after the queue was closed.
+	mutex   sync.RWMutex
+	closed  bool
+	workers sync.WaitGroup
+}
+
+//dispatchJob is one token in the queue together with the marshaled Message.
+type dispatchJob struct {
+	message *Message
+	encoded encodedMessage
+	err     error
+	token   string
+}
+
+//NewDispatcher creates a new Dispatcher that sends through the given Connection
+//or ConnectionPool and starts its workers. workers is the number of concurrent requests, queueSize
+//the number of tokens that can be waiting for a worker before Dispatch blocks.
+func NewDispatcher(sender Sender, workers int, queueSize int) *Dispatcher {
+	if workers <= 0 {
+		workers = DefaultDispatcherWorkers
+	}
+	if queueSize <= 0 {
+		queueSize = DefaultDispatcherQueueSize
+	}
+
+	d := &Dispatcher{
+		sender:    sender,
+		jobs:      make(chan dispatchJob, queueSize),
+		responses: make(chan Response, queueSize),
+	}
+
+	d.workers.Add(workers)
+	for i := 0; i < workers; i++ {
+		go d.work()
+	}
+	return d
+}
+
+//Responses returns the channel that receives one Response for every dispatched token.
+//It is closed after Close() was called and every queued token has been sent.
+func (d *Dispatcher) Responses() <-chan Response {
+	return d.responses
+}
+
+//Dispatch queues the Message for the given token.
+//It blocks while the queue is full.
+func (d *Dispatcher) Dispatch(message *Message, token string) error {
+	return d.DispatchContext(context.Background(), message, []string{token})
+}
+
+//Push queues the Message for every token. The Message is marshaled only once.
+//It blocks while the queue is full.
+func (d *Dispatcher) Push(message *Message, tokens []string) error {
+	return d.DispatchContext(context.Background(), message, tokens)
+}
+
+//DispatchContext queues the Message for every token. It blocks while the queue is full
+//and returns the error of the context if it is done before every token was queued.
+//Tokens that were queued before the context was done will still be sent.
+func (d *Dispatcher) DispatchContext(ctx context.Context, message *Message, tokens []string) error {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	if d.closed {
+		return ErrorDispatcherClosed
+	}
+
+	//A marshal error is reported once per token, just like a failed request.
+	encoded, err := message.encode()
+
+	for _, token := range tokens {
+		job := dispatchJob{message: message, encoded: encoded, err: err, token: token}
+		select {
+		case d.jobs <- job:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+//Close stops accepting new notifications and waits until every queued token is sent.
+//Afterwards the Responses() channel is closed.
+func (d *Dispatcher) Close() {
+	d.mutex.Lock()
+	if d.closed {
+		d.mutex.Unlock()
+		return
+	}
+	d.closed = true
+	close(d.jobs)
+	d.mutex.Unlock()
+
+	d.workers.Wait()
+	close(d.responses)
+}
+
+//work sends queued jobs until the queue is closed.
+func (d *Dispatcher) work() {
+	defer d.workers.Done()
+
+	for job := range d.jobs {
+		if job.err != nil {
+			d.responses <- newErrorResponse(job.message, job.token, job.err)
+			continue
+		}
+		d.responses <- senderOf(d.sender).send(context.Background(), job.message, job.encoded, job.token)
+	}
+}
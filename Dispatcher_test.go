@@ -0,0 +1,69 @@
+package goapns_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tantalum73/Go-APNS"
+)
+
+func TestDispatcherBoundedConcurrency(t *testing.T) {
+	conn := mockConnection(t)
+
+	var inFlight, maxInFlight int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := atomic.AddInt32(&inFlight, 1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if current <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, current) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+	}))
+	defer server.Close()
+	conn.Host = server.URL
+
+	tokens := make([]string, 50)
+	for i := range tokens {
+		tokens[i] = fmt.Sprintf("%064x", i)
+	}
+
+	dispatcher := goapns.NewDispatcher(conn, 4, 2)
+
+	received := make(map[string]bool)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for response := range dispatcher.Responses() {
+			assert.True(t, response.Sent())
+			received[response.Token] = true
+		}
+	}()
+
+	assert.Nil(t, dispatcher.Push(mockMessage(), tokens))
+	dispatcher.Close()
+	wg.Wait()
+
+	assert.Len(t, received, len(tokens))
+	assert.True(t, maxInFlight <= 4, "more requests in flight than workers: %v", maxInFlight)
+}
+
+func TestDispatcherClosed(t *testing.T) {
+	conn := mockConnection(t)
+
+	dispatcher := goapns.NewDispatcher(conn, 1, 1)
+	dispatcher.Close()
+
+	assert.Equal(t, goapns.ErrorDispatcherClosed, dispatcher.Dispatch(mockMessage(), testToken))
+	_, open := <-dispatcher.Responses()
+	assert.False(t, open)
+}
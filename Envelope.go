@@ -0,0 +1,80 @@
+package goapns
+
+import "encoding/json"
+
+//Envelope stores a complete Message, the Header fields as well as the payload.
+//Use it to persist notifications, for example in a queue, and to send them later:
+//MarshalJSON of a Message only produces the payload and loses the Header.
+//
+//	envelope, _ := message.Envelope()
+//	data, _ := json.Marshal(envelope)
+//	//...
+//	json.Unmarshal(data, &envelope)
+//	message, _ = envelope.Message()
+type Envelope struct {
+	//APNSID is the apns-id header.
+	APNSID string `json:"apns-id,omitempty"`
+	//Expiration is the apns-expiration header as UNIX epoch in seconds, 0 means no expiration.
+	Expiration int64 `json:"apns-expiration,omitempty"`
+	//Priority is the apns-priority header.
+	Priority int `json:"apns-priority,omitempty"`
+	//Topic is the apns-topic header without the suffix that is appended for the push type.
+	Topic string `json:"apns-topic,omitempty"`
+	//CollapseID is the apns-collapse-id header.
+	CollapseID string `json:"apns-collapse-id,omitempty"`
+	//PushType is the apns-push-type header if it was set explicitly.
+	PushType PushType `json:"apns-push-type,omitempty"`
+	//TruncateBody is the ellipsis if TruncateBody was called on the Message, nil otherwise.
+	TruncateBody *string `json:"truncate-body,omitempty"`
+	//Payload is the JSON of the Message as it is sent to Apples servers, without truncation.
+	Payload json.RawMessage `json:"payload"`
+}
+
+//Envelope returns the Envelope of the Message that can be stored as JSON.
+//It returns an error if the payload can not be marshaled.
+func (m *Message) Envelope() (Envelope, error) {
+	payload, err := m.marshal()
+	if err != nil {
+		return Envelope{}, err
+	}
+
+	e := Envelope{
+		APNSID:     m.Header.APNSID,
+		Priority:   m.Header.Priority,
+		Topic:      m.Header.Topic,
+		CollapseID: m.Header.CollapseID,
+		PushType:   m.Header.PushType,
+		Payload:    payload,
+	}
+	if !m.Header.Expiration.IsZero() {
+		e.Expiration = m.Header.Expiration.Unix()
+	}
+	if m.truncate {
+		ellipsis := m.ellipsis
+		e.TruncateBody = &ellipsis
+	}
+	return e, nil
+}
+
+//Message reconstructs the Message that is stored in the Envelope.
+func (e *Envelope) Message() (*Message, error) {
+	m := NewMessage()
+	if len(e.Payload) > 0 {
+		if err := json.Unmarshal(e.Payload, m); err != nil {
+			return nil, err
+		}
+	}
+
+	m.Header.APNSID = e.APNSID
+	m.Header.Topic = e.Topic
+	m.Header.CollapseID = e.CollapseID
+	m.Header.PushType = e.PushType
+	if e.Priority != 0 {
+		m.Header.Priority = e.Priority
+	}
+	m.Header.Expiration = unixTime(e.Expiration)
+	if e.TruncateBody != nil {
+		m.TruncateBody(*e.TruncateBody)
+	}
+	return m, nil
+}
@@ -0,0 +1,101 @@
+package goapns_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tantalum73/Go-APNS"
+)
+
+func TestMessageUnmarshalRoundTrip(t *testing.T) {
+	for name, message := range goldenMessages {
+		data, err := message().MarshalJSON()
+		assert.Nil(t, err, name)
+
+		decoded := goapns.NewMessage()
+		assert.Nil(t, json.Unmarshal(data, decoded), name)
+
+		again, err := decoded.MarshalJSON()
+		assert.Nil(t, err, name)
+		assert.Equal(t, string(data), string(again), name)
+	}
+}
+
+func TestMessageUnmarshal(t *testing.T) {
+	data := []byte(`{"aps":{"alert":"body","sound":{"critical":1,"name":"alarm.aiff","volume":0.5}},"match":{"score":"2:1"},"id":7}`)
+
+	m := goapns.NewMessage()
+	assert.Nil(t, json.Unmarshal(data, m))
+
+	assert.Equal(t, "body", m.Alert.Body)
+	assert.Equal(t, goapns.NewCriticalSound("alarm.aiff", 0.5), m.Payload.CriticalSound)
+	//Defaults of NewMessage are kept for missing values.
+	assert.Equal(t, -1, m.Payload.Badge)
+	assert.Equal(t, goapns.PriorityHigh, m.Header.Priority)
+
+	score, found := m.CustomValue("match", "score")
+	assert.True(t, found)
+	assert.Equal(t, "2:1", score)
+	id, _ := m.CustomValue("id")
+	assert.Equal(t, float64(7), id)
+
+	assert.NotNil(t, json.Unmarshal([]byte(`{"aps":{"badge":"one"}}`), m))
+}
+
+func TestMessageUnmarshalZeroMessage(t *testing.T) {
+	var m goapns.Message
+	assert.Nil(t, json.Unmarshal([]byte(`{"aps":{"alert":"body"}}`), &m))
+	assert.Equal(t, goapns.NewHeader(), m.Header)
+	assert.Nil(t, m.Validate())
+
+	conn := mockConnection(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	conn.Host = server.URL
+
+	response, err := conn.Send(context.Background(), &m, testToken)
+	assert.Nil(t, err)
+	assert.True(t, response.Sent())
+}
+
+func TestEnvelopeRoundTrip(t *testing.T) {
+	expiration := time.Unix(1700000000, 0)
+	m := goapns.NewMessage().Title("Title").Body("body").Badge(0).Custom("key", "value")
+	m.APNSID("123e4567-e89b-12d3-a456-426655440000").Topic("com.example.app").CollapseID("score")
+	m.Expiration(expiration).PriorityLow().PushType(goapns.PushTypeAlert).TruncateBody("...")
+
+	envelope, err := m.Envelope()
+	assert.Nil(t, err)
+	data, err := json.Marshal(envelope)
+	assert.Nil(t, err)
+
+	var stored goapns.Envelope
+	assert.Nil(t, json.Unmarshal(data, &stored))
+	restored, err := stored.Message()
+	assert.Nil(t, err)
+
+	assert.Equal(t, m.Header.APNSID, restored.Header.APNSID)
+	assert.Equal(t, m.Header.Topic, restored.Header.Topic)
+	assert.Equal(t, m.Header.CollapseID, restored.Header.CollapseID)
+	assert.Equal(t, goapns.PriorityLow, restored.Header.Priority)
+	assert.Equal(t, goapns.PushTypeAlert, restored.Header.PushType)
+	assert.True(t, expiration.Equal(restored.Header.Expiration))
+	assert.Equal(t, m.Alert, restored.Alert)
+	assert.Equal(t, m.Payload, restored.Payload)
+
+	original, _ := m.MarshalJSON()
+	restoredJSON, _ := restored.MarshalJSON()
+	assert.Equal(t, string(original), string(restoredJSON))
+
+	//Without header fields the defaults of NewMessage are used.
+	restored, err = (&goapns.Envelope{Payload: json.RawMessage(`{"aps":{"content-available":1}}`)}).Message()
+	assert.Nil(t, err)
+	assert.Equal(t, goapns.PriorityHigh, restored.Header.Priority)
+	assert.True(t, restored.Header.Expiration.IsZero())
+	assert.Equal(t, 1, restored.Payload.ContentAvailable)
+}
@@ -0,0 +1,64 @@
+package goapns_test
+
+import (
+	"sync"
+	"time"
+)
+
+//fakeClock is a Clock that only moves when Advance is called.
+type fakeClock struct {
+	mutex   sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+type fakeWaiter struct {
+	at      time.Time
+	channel chan time.Time
+}
+
+func newFakeClock(now time.Time) *fakeClock {
+	return &fakeClock{now: now}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	channel := make(chan time.Time, 1)
+	if d <= 0 {
+		channel <- c.now
+		return channel
+	}
+	c.waiters = append(c.waiters, fakeWaiter{at: c.now.Add(d), channel: channel})
+	return channel
+}
+
+//waiting returns the number of channels of After that did not fire yet.
+func (c *fakeClock) waiting() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.waiters)
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.now = c.now.Add(d)
+	waiting := c.waiters[:0]
+	for _, waiter := range c.waiters {
+		if waiter.at.After(c.now) {
+			waiting = append(waiting, waiter)
+			continue
+		}
+		waiter.channel <- c.now
+	}
+	c.waiters = waiting
+}
@@ -1,9 +1,22 @@
 package goapns
 
 import (
+	"errors"
+	"strings"
 	"time"
 )
 
+var (
+	//ErrorInvalidPushType is returned if the push type is not one of the types Apple knows.
+	ErrorInvalidPushType = errors.New("The apns-push-type is not supported.")
+	//ErrorPushTypePriorityMismatch is returned if the priority is not allowed for the push type,
+	//for example a background notification with PriorityHigh.
+	ErrorPushTypePriorityMismatch = errors.New("The apns-priority is not allowed for the apns-push-type.")
+	//ErrorPushTypeTopicMismatch is returned if the topic has the suffix of another push type,
+	//for example a .voip topic for an alert notification.
+	ErrorPushTypeTopicMismatch = errors.New("The apns-topic does not match the apns-push-type.")
+)
+
 //PriorityHigh sepcifies a high priority for the notification.
 //Default priotity is PriorityHigh so if you don't specify any priority,
 //PriorityHigh is assumed.
@@ -22,6 +35,56 @@ const PriorityHigh = 10
 //They are throttled, and in some cases are not delivered.
 const PriorityLow = 5
 
+//PriorityLowest specifies the lowest priority for the notification.
+//
+//Send the push message with the lowest priority to prioritize power considerations over all other factors.
+//Use it for notifications that are never presented, for example to update data.
+const PriorityLowest = 1
+
+//PushType describes the type of the notification, it is sent in the apns-push-type header.
+//Apple requires it to match the content of the notification.
+type PushType string
+
+//The push types that are supported by Apple.
+const (
+	//PushTypeAlert is used for notifications that display an alert, play a sound or badge the app icon.
+	PushTypeAlert PushType = "alert"
+	//PushTypeBackground is used for notifications that only deliver content in the background (content-available).
+	//It requires PriorityLow.
+	PushTypeBackground PushType = "background"
+	//PushTypeVoIP is used for notifications that provide information about an incoming Voice-over-IP call.
+	PushTypeVoIP PushType = "voip"
+	//PushTypeComplication is used for notifications that contain update information for a watchOS app’s complications.
+	PushTypeComplication PushType = "complication"
+	//PushTypeFileProvider is used to signal changes to a File Provider extension.
+	PushTypeFileProvider PushType = "fileprovider"
+	//PushTypeMDM is used for notifications that tell managed devices to contact the MDM server.
+	PushTypeMDM PushType = "mdm"
+	//PushTypeLocation is used for notifications that request a user’s location.
+	PushTypeLocation PushType = "location"
+	//PushTypeLiveActivity is used for notifications that start, update or end a live activity.
+	PushTypeLiveActivity PushType = "liveactivity"
+	//PushTypePushToTalk is used for notifications that provide information about an incoming Push to Talk audio.
+	//It requires PriorityHigh.
+	PushTypePushToTalk PushType = "pushtotalk"
+)
+
+//pushTypeTopicSuffixes maps push types to the suffix Apple expects on the topic (your bundle ID).
+var pushTypeTopicSuffixes = map[PushType]string{
+	PushTypeVoIP:         ".voip",
+	PushTypeComplication: ".complication",
+	PushTypeFileProvider: ".pushkit.fileprovider",
+	PushTypeLocation:     ".location-query",
+	PushTypeLiveActivity: TopicSuffixLiveActivity,
+	PushTypePushToTalk:   ".voip-ptt",
+}
+
+//TopicSuffix returns the suffix that Apple expects on the topic for this push type.
+//It is empty for push types that are sent to the plain bundle ID.
+func (p PushType) TopicSuffix() string {
+	return pushTypeTopicSuffixes[p]
+}
+
 //Header collects the header fields for the notification.
 type Header struct {
 
@@ -41,8 +104,8 @@ type Header struct {
 	Expiration time.Time
 
 	//Priority is the priority of the notification.
-	//Set it to PriorityHigh or PriorityLow but do not
-	//use custom numbers in any case.
+	//Set it to PriorityHigh, PriorityLow or PriorityLowest but do not
+	//use custom numbers in any case. 0 leaves it unset, Apple assumes PriorityHigh then.
 	Priority int
 
 	//Topic of the remote notification, which is typically the bundle ID for your app.
@@ -56,10 +119,56 @@ type Header struct {
 	//Notifications with the same CollapseID string will be collapsed so that only the newest notification is displayed.
 	//Usefull if you want to present a  scrore of a fotball math or something that gets frequently updated.
 	CollapseID string
+
+	//PushType is the type of the notification.
+	//If it is empty, it is inferred from the content of the Message when it is pushed.
+	PushType PushType
 }
 
 //NewHeader creates a new Header with high priority.
 func NewHeader() Header {
-	h := Header{"", time.Time{}, PriorityHigh, "", ""}
+	h := Header{Priority: PriorityHigh}
 	return h
 }
+
+//topic returns the Topic with the suffix that is required by the push type.
+//It is left as it is if it is empty or already has the suffix.
+func (h *Header) topic(pushType PushType) string {
+	suffix := pushType.TopicSuffix()
+	if h.Topic == "" || suffix == "" || strings.HasSuffix(h.Topic, suffix) {
+		return h.Topic
+	}
+	return h.Topic + suffix
+}
+
+//validate checks that priority and topic of the Header are allowed for the push type.
+func (h *Header) validate(pushType PushType) error {
+	switch pushType {
+	case PushTypeAlert, PushTypeVoIP, PushTypeComplication, PushTypeFileProvider, PushTypeMDM, PushTypeLocation, PushTypeLiveActivity:
+	case PushTypeBackground:
+		//Apple assumes PriorityHigh if the priority is not set.
+		if h.Priority == PriorityHigh || h.Priority == 0 {
+			return ErrorPushTypePriorityMismatch
+		}
+	case PushTypePushToTalk:
+		if h.Priority == PriorityLow {
+			return ErrorPushTypePriorityMismatch
+		}
+	default:
+		return ErrorInvalidPushType
+	}
+
+	//The topic must not carry the suffix of another push type.
+	//Suffixes are compared longest first because .voip is contained in .voip-ptt.
+	expected := pushType.TopicSuffix()
+	matched := ""
+	for _, suffix := range pushTypeTopicSuffixes {
+		if strings.HasSuffix(h.Topic, suffix) && len(suffix) > len(matched) {
+			matched = suffix
+		}
+	}
+	if matched != "" && matched != expected {
+		return ErrorPushTypeTopicMismatch
+	}
+	return nil
+}
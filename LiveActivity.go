@@ -0,0 +1,108 @@
+package goapns
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	//ErrorInvalidLiveActivityEvent is returned if the event of a LiveActivity is not start, update or end.
+	ErrorInvalidLiveActivityEvent = errors.New("The event of a live activity must be start, update or end.")
+	//ErrorMissingContentState is returned if a start or update event of a LiveActivity has no content-state.
+	ErrorMissingContentState = errors.New("The content-state of a live activity is required to start or update it.")
+	//ErrorMissingAttributes is returned if a start event of a LiveActivity has no attributes-type or attributes.
+	ErrorMissingAttributes = errors.New("The attributes-type and attributes of a live activity are required to start it.")
+)
+
+//TopicSuffixLiveActivity is appended to the topic (your bundle ID) of live activity notifications.
+const TopicSuffixLiveActivity = ".push-type.liveactivity"
+
+//LiveActivityEvent describes what a live activity notification does.
+type LiveActivityEvent string
+
+//The events that are supported by Apple.
+const (
+	//LiveActivityStart starts a new live activity, it requires attributes-type, attributes and content-state.
+	LiveActivityStart LiveActivityEvent = "start"
+	//LiveActivityUpdate updates the content-state of a running live activity.
+	LiveActivityUpdate LiveActivityEvent = "update"
+	//LiveActivityEnd ends a live activity. It stays on the lock screen until its dismissal-date.
+	LiveActivityEnd LiveActivityEvent = "end"
+)
+
+//LiveActivity stores the properties of a live activity notification.
+//Set it with the methods of a Message, starting with Message.LiveActivity(event).
+//The apns-push-type header is set to liveactivity and the topic gets the
+//TopicSuffixLiveActivity suffix automatically when such a Message is pushed.
+type LiveActivity struct {
+	//Event is start, update or end.
+	Event LiveActivityEvent
+
+	//Timestamp is the time at which the update was created. The system ignores
+	//updates that are older than the one currently displayed.
+	Timestamp time.Time
+
+	//ContentState is the updated dynamic content of the live activity.
+	//It must match the ContentState of the ActivityAttributes in your app.
+	ContentState map[string]interface{}
+
+	//DismissalDate is the time at which an ended live activity is removed from the lock screen.
+	DismissalDate time.Time
+
+	//StaleDate is the time at which the system considers the live activity to be outdated.
+	StaleDate time.Time
+
+	//AttributesType is the name of the ActivityAttributes struct of your app. Required to start a live activity.
+	AttributesType string
+
+	//Attributes are the static attributes of the live activity. Required to start a live activity.
+	Attributes map[string]interface{}
+}
+
+//NewLiveActivity creates a LiveActivity for the given event with the current time as Timestamp.
+func NewLiveActivity(event LiveActivityEvent) *LiveActivity {
+	return &LiveActivity{Event: event, Timestamp: time.Now()}
+}
+
+//Validate checks that the LiveActivity contains every property that is required by its event.
+func (l *LiveActivity) Validate() error {
+	switch l.Event {
+	case LiveActivityStart:
+		if l.AttributesType == "" || l.Attributes == nil {
+			return ErrorMissingAttributes
+		}
+		fallthrough
+	case LiveActivityUpdate:
+		if l.ContentState == nil {
+			return ErrorMissingContentState
+		}
+	case LiveActivityEnd:
+	default:
+		return ErrorInvalidLiveActivityEvent
+	}
+	return nil
+}
+
+//MapInto is passed in a map on which the LiveActivity content is appended to.
+//Dates are written as UNIX epoch in seconds as Apple expects them.
+func (l *LiveActivity) MapInto(mapped map[string]interface{}) map[string]interface{} {
+	mapped["event"] = l.Event
+	mapped["timestamp"] = l.Timestamp.Unix()
+
+	if l.ContentState != nil {
+		mapped["content-state"] = l.ContentState
+	}
+	if !l.DismissalDate.IsZero() {
+		mapped["dismissal-date"] = l.DismissalDate.Unix()
+	}
+	if !l.StaleDate.IsZero() {
+		mapped["stale-date"] = l.StaleDate.Unix()
+	}
+	if l.AttributesType != "" {
+		mapped["attributes-type"] = l.AttributesType
+	}
+	if l.Attributes != nil {
+		mapped["attributes"] = l.Attributes
+	}
+	return mapped
+}
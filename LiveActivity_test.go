@@ -0,0 +1,76 @@
+package goapns_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tantalum73/Go-APNS"
+)
+
+func TestLiveActivityJSON(t *testing.T) {
+	timestamp := time.Unix(1700000000, 0)
+	m := goapns.NewMessage().LiveActivity(goapns.LiveActivityStart).Timestamp(timestamp)
+	m.ContentState(map[string]interface{}{"score": "2:1"})
+	m.Attributes("MatchAttributes", map[string]interface{}{"home": "A", "away": "B"})
+	m.StaleDate(timestamp.Add(time.Hour))
+
+	data, err := m.MarshalJSON()
+	assert.Nil(t, err)
+
+	var decoded map[string]map[string]interface{}
+	assert.Nil(t, json.Unmarshal(data, &decoded))
+	aps := decoded["aps"]
+	assert.Equal(t, "start", aps["event"])
+	assert.Equal(t, float64(1700000000), aps["timestamp"])
+	assert.Equal(t, float64(1700003600), aps["stale-date"])
+	assert.Equal(t, "MatchAttributes", aps["attributes-type"])
+	assert.Equal(t, map[string]interface{}{"score": "2:1"}, aps["content-state"])
+	assert.Equal(t, map[string]interface{}{"home": "A", "away": "B"}, aps["attributes"])
+	assert.NotContains(t, aps, "dismissal-date")
+}
+
+func TestLiveActivityValidation(t *testing.T) {
+	m := goapns.NewMessage().LiveActivity(goapns.LiveActivityStart).ContentState(map[string]interface{}{})
+	_, err := m.MarshalJSON()
+	assert.Equal(t, goapns.ErrorMissingAttributes, err)
+
+	m = goapns.NewMessage().LiveActivity(goapns.LiveActivityUpdate)
+	_, err = m.MarshalJSON()
+	assert.Equal(t, goapns.ErrorMissingContentState, err)
+
+	m = goapns.NewMessage().LiveActivity("pause")
+	_, err = m.MarshalJSON()
+	assert.Equal(t, goapns.ErrorInvalidLiveActivityEvent, err)
+
+	m = goapns.NewMessage().LiveActivity(goapns.LiveActivityEnd).DismissalDate(time.Now())
+	_, err = m.MarshalJSON()
+	assert.Nil(t, err)
+}
+
+func TestLiveActivityHeader(t *testing.T) {
+	conn := mockConnection(t)
+
+	message := goapns.NewMessage().Topic("com.example.app").ContentState(map[string]interface{}{"score": "2:1"})
+	assert.Equal(t, goapns.LiveActivityUpdate, message.Payload.LiveActivity.Event)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "liveactivity", r.Header.Get("apns-push-type"))
+		assert.Equal(t, "com.example.app"+goapns.TopicSuffixLiveActivity, r.Header.Get("apns-topic"))
+		body, _ := ioutil.ReadAll(r.Body)
+		assert.Contains(t, string(body), `"event":"update"`)
+	}))
+	defer server.Close()
+
+	channel := make(chan goapns.Response, 1)
+
+	conn.Host = server.URL
+	conn.Push(message, []string{testToken}, channel)
+	for response := range channel {
+		assert.True(t, response.Sent())
+	}
+}
@@ -0,0 +1,40 @@
+package goapns
+
+//Logger receives the log messages of a Connection. Every message comes with structured
+//fields as alternating keys and values, for example "token", "<token>", "status", 410.
+//The keys that are used are token, apns-id, status, reason, attempts, deferred-until and error.
+//
+//*slog.Logger implements Logger, NewSlogLogger creates one for you.
+//A Connection without Logger is silent.
+type Logger interface {
+	Debug(msg string, keysAndValues ...interface{})
+	Info(msg string, keysAndValues ...interface{})
+	Warn(msg string, keysAndValues ...interface{})
+	Error(msg string, keysAndValues ...interface{})
+}
+
+//nopLogger discards every message, it is used if no Logger is set.
+type nopLogger struct{}
+
+func (nopLogger) Debug(msg string, keysAndValues ...interface{}) {}
+func (nopLogger) Info(msg string, keysAndValues ...interface{})  {}
+func (nopLogger) Warn(msg string, keysAndValues ...interface{})  {}
+func (nopLogger) Error(msg string, keysAndValues ...interface{}) {}
+
+//responseFields returns the structured fields that describe the Response.
+func responseFields(response Response) []interface{} {
+	fields := []interface{}{"token", response.Token, "apns-id", response.APNSID, "status", response.StatusCode}
+	if response.Reason != "" {
+		fields = append(fields, "reason", response.Reason)
+	}
+	if response.Attempts > 0 {
+		fields = append(fields, "attempts", response.Attempts)
+	}
+	if !response.DeferredUntil.IsZero() {
+		fields = append(fields, "deferred-until", response.DeferredUntil)
+	}
+	if response.Error != nil {
+		fields = append(fields, "error", response.Error)
+	}
+	return fields
+}
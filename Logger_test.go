@@ -0,0 +1,79 @@
+package goapns_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tantalum73/Go-APNS"
+)
+
+type logEntry struct {
+	level  string
+	msg    string
+	fields map[string]interface{}
+}
+
+//recordingLogger stores every message it receives.
+type recordingLogger struct {
+	mutex   sync.Mutex
+	entries []logEntry
+}
+
+func (l *recordingLogger) record(level string, msg string, keysAndValues []interface{}) {
+	fields := make(map[string]interface{})
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		fields[keysAndValues[i].(string)] = keysAndValues[i+1]
+	}
+	l.mutex.Lock()
+	l.entries = append(l.entries, logEntry{level, msg, fields})
+	l.mutex.Unlock()
+}
+
+func (l *recordingLogger) Debug(msg string, keysAndValues ...interface{}) {
+	l.record("debug", msg, keysAndValues)
+}
+func (l *recordingLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.record("info", msg, keysAndValues)
+}
+func (l *recordingLogger) Warn(msg string, keysAndValues ...interface{}) {
+	l.record("warn", msg, keysAndValues)
+}
+func (l *recordingLogger) Error(msg string, keysAndValues ...interface{}) {
+	l.record("error", msg, keysAndValues)
+}
+
+func TestConnectionLogger(t *testing.T) {
+	conn := mockConnection(t)
+	tokens := testTokens(3)
+	logger := &recordingLogger{}
+	conn.Logger = logger
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("apns-id", "123e4567-e89b-12d3-a456-426655440000")
+		if r.URL.Path == "/3/device/"+tokens[2] {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"reason":"BadTopic"}`))
+		}
+	}))
+	defer server.Close()
+	conn.Host = server.URL
+
+	response, _ := conn.Send(context.Background(), mockMessage(), tokens[1])
+	assert.Equal(t, "123e4567-e89b-12d3-a456-426655440000", response.APNSID)
+	conn.Send(context.Background(), mockMessage(), tokens[2])
+
+	assert.Len(t, logger.entries, 2)
+	assert.Equal(t, "debug", logger.entries[0].level)
+	assert.Equal(t, tokens[1], logger.entries[0].fields["token"])
+	assert.Equal(t, http.StatusOK, logger.entries[0].fields["status"])
+
+	assert.Equal(t, "warn", logger.entries[1].level)
+	assert.Equal(t, tokens[2], logger.entries[1].fields["token"])
+	assert.Equal(t, "123e4567-e89b-12d3-a456-426655440000", logger.entries[1].fields["apns-id"])
+	assert.Equal(t, "BadTopic", logger.entries[1].fields["reason"])
+	assert.Equal(t, goapns.ErrorBadTopic, logger.entries[1].fields["error"])
+}
@@ -3,9 +3,17 @@ package goapns
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"time"
 )
 
+var (
+	//ErrorReservedCustomKey is returned if a custom value uses the key aps which is reserved by Apple.
+	ErrorReservedCustomKey = errors.New("The custom key aps is reserved by Apple.")
+	//ErrorCustomNotDictionary is returned by MergeCustom if the object is not encoded as a JSON dictionary.
+	ErrorCustomNotDictionary = errors.New("Only maps and structs can be merged into the custom values.")
+)
+
 //Message collects Header, Payload and Alert and also provides methods to configure them.
 type Message struct {
 
@@ -20,6 +28,13 @@ type Message struct {
 
 	//custom stores custom keys and values the user set. It will be passed into your app as a dictionary as the user launches it.
 	custom map[string]interface{}
+
+	//truncate and ellipsis are set by TruncateBody.
+	truncate bool
+	ellipsis string
+
+	//compactAlert is set by CompactAlert.
+	compactAlert bool
 }
 
 //NewMessage creates a new Message with default Alert, Payload and Header objects.
@@ -110,8 +125,17 @@ func (m *Message) LaunchImage(imageName string) *Message {
 	return m
 }
 
+//CompactAlert lets the alert be sent as plain string instead of a dictionary
+//if only the body is set. It saves some bytes of your payload.
+//If other properties of the alert are set, the dictionary is used anyway.
+func (m *Message) CompactAlert() *Message {
+	m.compactAlert = true
+	return m
+}
+
 /******************************
-Configuring Payload: Badge, Sound, ContentAvailable, Category, MutableContent
+Configuring Payload: Badge, Sound, CriticalSound, ContentAvailable, Category, MutableContent,
+ThreadID, InterruptionLevel, RelevanceScore, ClearRelevanceScore, TargetContentID, FilterCriteria
 ******************************/
 
 //Badge is the number to display as the badge of the app icon.
@@ -139,6 +163,19 @@ func (m *Message) NoBadgeChange() *Message {
 //The audio must be in one of the audio data formats that are compatible with system sounds.
 func (m *Message) Sound(sound string) *Message {
 	m.Payload.Sound = sound
+	m.Payload.CriticalSound = nil
+	return m
+}
+
+//CriticalSound specifies a sound that is played even if the device is muted or in Do Not Disturb.
+//This method sets the value to its underlaying Payload object.
+//The volume must be between 0.0 (silent) and 1.0 (full volume), other values are
+//rejected with ErrorInvalidSoundVolume when the Message is marshaled.
+//Your app needs the critical alerts entitlement, you may want to set
+//InterruptionLevel(InterruptionLevelCritical) as well.
+func (m *Message) CriticalSound(sound string, volume float64) *Message {
+	m.Payload.Sound = ""
+	m.Payload.CriticalSound = NewCriticalSound(sound, volume)
 	return m
 }
 
@@ -177,8 +214,116 @@ func (m *Message) MutableContent() *Message {
 	return m
 }
 
+//ThreadID is an app-specific identifier for grouping related notifications.
+//This method sets the value to its underlaying Payload object.
+func (m *Message) ThreadID(threadID string) *Message {
+	m.Payload.ThreadID = threadID
+	return m
+}
+
+//InterruptionLevel indicates the importance and delivery timing of a notification.
+//This method sets the value to its underlaying Payload object.
+//Use one of InterruptionLevelPassive, InterruptionLevelActive, InterruptionLevelTimeSensitive
+//or InterruptionLevelCritical. Other values are rejected with ErrorInvalidInterruptionLevel when the Message is marshaled.
+func (m *Message) InterruptionLevel(level InterruptionLevel) *Message {
+	m.Payload.InterruptionLevel = level
+	return m
+}
+
+//RelevanceScore is a number between 0.0 and 1.0 that the system uses to sort the notifications from your app.
+//This method sets the value to its underlaying Payload object.
+//Values outside of this range are rejected with ErrorInvalidRelevanceScore when the Message is marshaled.
+func (m *Message) RelevanceScore(score float64) *Message {
+	m.Payload.RelevanceScore = &score
+	return m
+}
+
+//ClearRelevanceScore removes the relevance score, so the system uses its default.
+//This method sets the value to its underlaying Payload object.
+func (m *Message) ClearRelevanceScore() *Message {
+	m.Payload.RelevanceScore = nil
+	return m
+}
+
+//TargetContentID is the identifier of the window brought forward when the notification is opened.
+//This method sets the value to its underlaying Payload object.
+func (m *Message) TargetContentID(id string) *Message {
+	m.Payload.TargetContentID = id
+	return m
+}
+
+//FilterCriteria is the criteria the system evaluates to determine if it displays the notification in the current Focus.
+//This method sets the value to its underlaying Payload object.
+func (m *Message) FilterCriteria(criteria string) *Message {
+	m.Payload.FilterCriteria = criteria
+	return m
+}
+
 /******************************
-Configuring Header: APNSID, Expiration, Priority, Topic, CollapseID
+Configuring LiveActivity: LiveActivity, Timestamp, ContentState, StaleDate, DismissalDate, Attributes
+******************************/
+
+//LiveActivity turns the Message into a live activity notification for the given event
+//(LiveActivityStart, LiveActivityUpdate or LiveActivityEnd). The timestamp is set to the current time.
+//This method sets the value to its underlaying Payload object.
+//When the Message is pushed, the apns-push-type header is set to liveactivity
+//and TopicSuffixLiveActivity is appended to its Topic.
+func (m *Message) LiveActivity(event LiveActivityEvent) *Message {
+	m.Payload.LiveActivity = NewLiveActivity(event)
+	return m
+}
+
+//Timestamp is the time at which the live activity update was created.
+//The system ignores updates that are older than the one currently displayed.
+//This method sets the value to the LiveActivity of its underlaying Payload object.
+func (m *Message) Timestamp(timestamp time.Time) *Message {
+	m.liveActivity().Timestamp = timestamp
+	return m
+}
+
+//ContentState is the updated dynamic content of the live activity. It is required
+//to start or update one and must match the ContentState of the ActivityAttributes in your app.
+//This method sets the value to the LiveActivity of its underlaying Payload object.
+func (m *Message) ContentState(state map[string]interface{}) *Message {
+	m.liveActivity().ContentState = state
+	return m
+}
+
+//StaleDate is the time at which the system considers the live activity to be outdated.
+//This method sets the value to the LiveActivity of its underlaying Payload object.
+func (m *Message) StaleDate(date time.Time) *Message {
+	m.liveActivity().StaleDate = date
+	return m
+}
+
+//DismissalDate is the time at which an ended live activity is removed from the lock screen.
+//This method sets the value to the LiveActivity of its underlaying Payload object.
+func (m *Message) DismissalDate(date time.Time) *Message {
+	m.liveActivity().DismissalDate = date
+	return m
+}
+
+//Attributes specifies the name of the ActivityAttributes struct of your app and its static attributes.
+//They are required to start a live activity.
+//This method sets the value to the LiveActivity of its underlaying Payload object.
+func (m *Message) Attributes(attributesType string, attributes map[string]interface{}) *Message {
+	live := m.liveActivity()
+	live.AttributesType = attributesType
+	live.Attributes = attributes
+	return m
+}
+
+//liveActivity returns the LiveActivity of the Payload.
+//If there is none yet, an update is created.
+func (m *Message) liveActivity() *LiveActivity {
+	if m.Payload.LiveActivity == nil {
+		m.Payload.LiveActivity = NewLiveActivity(LiveActivityUpdate)
+	}
+	return m.Payload.LiveActivity
+}
+
+/******************************
+Configuring Header: APNSID, Expiration, Priority, Topic, CollapseID, PushType
 ******************************/
 
 //APNSID is a canonical UUID that identifies the notification.
@@ -249,28 +394,194 @@ func (m *Message) CollapseID(collapseID string) *Message {
 	return m
 }
 
+//PushType specifies the type of the notification that is sent in the apns-push-type header.
+//This method sets the value to its underlaying Header object.
+//If you do not specify one, it is inferred from the content of the Message, see InferredPushType().
+//The suffix that Apple expects for the push type is appended to the Topic when the Message is pushed.
+func (m *Message) PushType(pushType PushType) *Message {
+	m.Header.PushType = pushType
+	return m
+}
+
+//InferredPushType returns the push type that is sent with the Message.
+//It is the PushType of the Header if one is set. Otherwise it is PushTypeLiveActivity
+//for live activities, PushTypeBackground if ContentAvailable() is set without an alert,
+//sound or badge and PushTypeAlert in every other case.
+func (m *Message) InferredPushType() PushType {
+	if m.Header.PushType != "" {
+		return m.Header.PushType
+	}
+	if m.Payload.LiveActivity != nil {
+		return PushTypeLiveActivity
+	}
+	if m.Payload.ContentAvailable != 0 && m.Alert.IsEmpty() && m.Payload.Sound == "" && m.Payload.CriticalSound == nil && m.Payload.Badge < 0 {
+		return PushTypeBackground
+	}
+	return PushTypeAlert
+}
+
 /******************************
 Custom parameter
 ******************************/
 
 //Custom lets you set a custom key and value. It will be appended to the Notification.
 //In your AppDelegate, you can extract those custom values.
+//You can call it multiple times to set multiple keys, setting a key again replaces its value.
+//The key aps is reserved by Apple, the Message can not be marshaled if you use it.
 func (m *Message) Custom(key string, object interface{}) *Message {
-	m.custom = make(map[string]interface{})
-	m.custom[key] = object
+	return m.CustomAt([]string{key}, object)
+}
+
+//CustomAt sets a custom value at a nested key path. CustomAt([]string{"match", "score"}, "2:1")
+//results in {"match": {"score": "2:1"}}. Dictionaries along the path are created if necessary,
+//other values along the path are replaced by dictionaries.
+func (m *Message) CustomAt(path []string, object interface{}) *Message {
+	if len(path) == 0 {
+		return m
+	}
+	if m.custom == nil {
+		m.custom = make(map[string]interface{})
+	}
+
+	parent := m.custom
+	for _, key := range path[:len(path)-1] {
+		parent = ownDictionary(parent, key)
+	}
+	parent[path[len(path)-1]] = object
+	return m
+}
+
+//CustomValue returns the custom value at the key path and true if it exists.
+//Pass one key to read a top level value and multiple keys to read a nested one.
+func (m *Message) CustomValue(path ...string) (interface{}, bool) {
+	if len(path) == 0 {
+		return nil, false
+	}
+
+	var value interface{} = m.custom
+	for _, key := range path {
+		dictionary, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		value, ok = dictionary[key]
+		if !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+//DeleteCustom removes the custom value at the key path. Nothing happens if it does not exist.
+func (m *Message) DeleteCustom(path ...string) *Message {
+	if len(path) == 0 {
+		return m
+	}
+
+	parent := m.custom
+	for _, key := range path[:len(path)-1] {
+		if _, ok := parent[key].(map[string]interface{}); !ok {
+			return m
+		}
+		parent = ownDictionary(parent, key)
+	}
+	delete(parent, path[len(path)-1])
 	return m
 }
 
+//ownDictionary replaces the dictionary at the key by a copy, or by an empty one if there is none,
+//and returns it. A dictionary that was passed to Custom belongs to the caller, so the Message
+//changes its own copy instead.
+func ownDictionary(parent map[string]interface{}, key string) map[string]interface{} {
+	existing, _ := parent[key].(map[string]interface{})
+	child := make(map[string]interface{}, len(existing))
+	for k, v := range existing {
+		child[k] = v
+	}
+	parent[key] = child
+	return child
+}
+
+//MergeCustom merges the keys of a map or the JSON fields of a struct into the custom values.
+//Nested dictionaries are merged recursively, every other value replaces an existing one.
+//It returns ErrorCustomNotDictionary if object is not encoded as a JSON dictionary and
+//ErrorReservedCustomKey if it contains the key aps. Nothing is merged in this cases.
+func (m *Message) MergeCustom(object interface{}) error {
+	dictionary, ok := object.(map[string]interface{})
+	if !ok {
+		data, err := json.Marshal(object)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, &dictionary); err != nil || dictionary == nil {
+			return ErrorCustomNotDictionary
+		}
+	}
+	if _, reserved := dictionary["aps"]; reserved {
+		return ErrorReservedCustomKey
+	}
+
+	if m.custom == nil {
+		m.custom = make(map[string]interface{})
+	}
+	mergeDictionaries(m.custom, dictionary)
+	return nil
+}
+
+//mergeDictionaries copies every key of source into destination, nested dictionaries are merged.
+func mergeDictionaries(destination map[string]interface{}, source map[string]interface{}) {
+	for key, value := range source {
+		sourceChild, sourceIsDictionary := value.(map[string]interface{})
+		_, destinationIsDictionary := destination[key].(map[string]interface{})
+
+		if sourceIsDictionary && destinationIsDictionary {
+			mergeDictionaries(ownDictionary(destination, key), sourceChild)
+			continue
+		}
+		if sourceIsDictionary {
+			//Copying so that later changes to the source do not affect the Message.
+			copied := make(map[string]interface{}, len(sourceChild))
+			mergeDictionaries(copied, sourceChild)
+			value = copied
+		}
+		destination[key] = value
+	}
+}
+
 /******************************
 JSON encoding
 ******************************/
 
 //MarshalJSON builds a []byte that stores the Message object in JSON.
+//It returns an error if the Payload contains values that are not allowed by Apple.
+//If TruncateBody was called, the body is shortened so that the JSON fits the maximum payload size.
 func (m *Message) MarshalJSON() ([]byte, error) {
+	encoded, err := m.encode()
+	return encoded.dataToSend, err
+}
+
+//marshal builds the JSON of the Message without truncation.
+func (m *Message) marshal() ([]byte, error) {
+	if err := m.Payload.Validate(); err != nil {
+		return nil, err
+	}
+
 	payload := make(map[string]interface{}, 4)
-	payload["alert"] = m.Alert
+	//An empty alert is omitted, otherwise a background notification would be treated as visible.
+	if !m.Alert.IsEmpty() {
+		if m.compactAlert && m.Alert.bodyOnly() {
+			payload["alert"] = m.Alert.Body
+		} else {
+			payload["alert"] = m.Alert
+		}
+	}
 	payload = m.Payload.MapInto(payload)
 
+	if _, reserved := m.custom["aps"]; reserved {
+		//A custom value must never replace the dictionary Apple reads.
+		return nil, ErrorReservedCustomKey
+	}
+
 	jsonMappedWithAPSKey := map[string]interface{}{"aps": payload}
 
 	for key, object := range m.custom {
@@ -0,0 +1,127 @@
+package goapns_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tantalum73/Go-APNS"
+)
+
+//update rewrites the golden files with the current output: go test -run TestMessageGolden -update
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+//goldenMessages are the combinations of alert and payload whose JSON is compared
+//with the files in testdata/golden.
+var goldenMessages = map[string]func() *goapns.Message{
+	"background": func() *goapns.Message {
+		return goapns.NewMessage().ContentAvailable()
+	},
+	"background-custom": func() *goapns.Message {
+		return goapns.NewMessage().ContentAvailable().Custom("id", 42)
+	},
+	"badge-only": func() *goapns.Message {
+		return goapns.NewMessage().Badge(3)
+	},
+	"sound-only": func() *goapns.Message {
+		return goapns.NewMessage().Sound("default")
+	},
+	"body": func() *goapns.Message {
+		return goapns.NewMessage().Body("body")
+	},
+	"body-compact": func() *goapns.Message {
+		return goapns.NewMessage().Body("body").CompactAlert()
+	},
+	"body-compact-badge-sound": func() *goapns.Message {
+		return goapns.NewMessage().Body("body").Badge(1).Sound("default").CompactAlert()
+	},
+	"title-body-compact": func() *goapns.Message {
+		return goapns.NewMessage().Title("Title").Body("body").CompactAlert()
+	},
+	"title-only-compact": func() *goapns.Message {
+		return goapns.NewMessage().Title("Title").CompactAlert()
+	},
+	"localized-compact": func() *goapns.Message {
+		return goapns.NewMessage().LocKey("key").LocArgs([]string{"1", "2"}).CompactAlert()
+	},
+	"alert-full": func() *goapns.Message {
+		array := []string{"1", "2"}
+		return goapns.NewMessage().Title("Title").Subtitle("Subtitle").
+			TitleLocKey("Tkey").TitleLocArgs(array).Body("body").
+			LocKey("Lkey").LocArgs(array).ActionLocKey("Akey").LaunchImage("imageName")
+	},
+	"mutable-content": func() *goapns.Message {
+		return goapns.NewMessage().Body("body").MutableContent().Category("category").CompactAlert()
+	},
+	"critical-sound": func() *goapns.Message {
+		return goapns.NewMessage().Body("body").CriticalSound("alarm.aiff", 0.5).
+			InterruptionLevel(goapns.InterruptionLevelCritical)
+	},
+	"live-activity": func() *goapns.Message {
+		return goapns.NewMessage().LiveActivity(goapns.LiveActivityUpdate).
+			Timestamp(time.Unix(1700000000, 0)).ContentState(map[string]interface{}{"score": 1})
+	},
+	"live-activity-alert": func() *goapns.Message {
+		return goapns.NewMessage().LiveActivity(goapns.LiveActivityUpdate).
+			Timestamp(time.Unix(1700000000, 0)).ContentState(map[string]interface{}{"score": 2}).
+			Title("Goal").Body("2:0")
+	},
+	"voip-custom": func() *goapns.Message {
+		return goapns.NewMessage().PushType(goapns.PushTypeVoIP).Custom("caller", "Alice")
+	},
+}
+
+func TestMessageGolden(t *testing.T) {
+	for name, message := range goldenMessages {
+		m := message()
+		data, err := m.MarshalJSON()
+		assert.Nil(t, err, name)
+		assert.Nil(t, m.Validate(), name)
+
+		//Golden files are indented to be readable, the comparison is made without whitespace.
+		path := filepath.Join("testdata", "golden", name+".json")
+		if *update {
+			var indented bytes.Buffer
+			assert.Nil(t, json.Indent(&indented, data, "", "  "))
+			indented.WriteString("\n")
+			assert.Nil(t, ioutil.WriteFile(path, indented.Bytes(), 0644))
+			continue
+		}
+
+		golden, err := ioutil.ReadFile(path)
+		if !assert.Nil(t, err, "missing golden file, run the tests with -update") {
+			continue
+		}
+		var expected bytes.Buffer
+		assert.Nil(t, json.Compact(&expected, golden), name)
+		assert.Equal(t, expected.String(), string(data), name)
+	}
+}
+
+func TestMessageOmitsEmptyAlert(t *testing.T) {
+	data, err := goapns.NewMessage().ContentAvailable().MarshalJSON()
+	assert.Nil(t, err)
+	assert.Equal(t, `{"aps":{"content-available":1}}`, string(data))
+}
+
+func TestMessageCompactAlertTruncated(t *testing.T) {
+	m := goapns.NewMessage().Body(strings.Repeat("a", 5000)).CompactAlert().TruncateBody("")
+
+	data, err := m.MarshalJSON()
+	assert.Nil(t, err)
+	assert.True(t, len(data) <= goapns.MaxPayloadSize)
+
+	var decoded struct {
+		APS struct {
+			Alert string `json:"alert"`
+		} `json:"aps"`
+	}
+	assert.Nil(t, json.Unmarshal(data, &decoded))
+	assert.NotEmpty(t, decoded.APS.Alert)
+}
@@ -67,3 +67,169 @@ func TestMessageJSON(t *testing.T) {
 
 	assert.Equal(t, expected, json)
 }
+
+func TestMessageModernPayloadKeys(t *testing.T) {
+	m := goapns.NewMessage().Body("body")
+	m.ThreadID("thread").InterruptionLevel(goapns.InterruptionLevelTimeSensitive).RelevanceScore(0.5)
+	m.TargetContentID("window").FilterCriteria("work")
+
+	expected := []byte(`{"aps":{"alert":{"body":"body"},"filter-criteria":"work","interruption-level":"time-sensitive","relevance-score":0.5,"target-content-id":"window","thread-id":"thread"}}`)
+	json, err := m.MarshalJSON()
+	assert.Nil(t, err)
+	assert.Equal(t, string(expected), string(json))
+
+	//Removing the relevance score again.
+	m.ClearRelevanceScore()
+	json, _ = m.MarshalJSON()
+	assert.NotContains(t, string(json), "relevance-score")
+}
+
+func TestPayloadZeroValueOmitsRelevanceScore(t *testing.T) {
+	var p goapns.Payload
+	assert.NotContains(t, p.MapInto(make(map[string]interface{})), "relevance-score")
+	assert.Nil(t, p.Validate())
+
+	m := &goapns.Message{}
+	m.Alert.Body = "body"
+	json, err := m.MarshalJSON()
+	assert.Nil(t, err)
+	assert.NotContains(t, string(json), "relevance-score")
+
+	//A relevance score of 0 is sent if it was set.
+	json, _ = m.RelevanceScore(0).MarshalJSON()
+	assert.Contains(t, string(json), `"relevance-score":0`)
+}
+
+func TestMessageModernPayloadValidation(t *testing.T) {
+	m := goapns.NewMessage().Body("body").InterruptionLevel("loud")
+	_, err := m.MarshalJSON()
+	assert.Equal(t, goapns.ErrorInvalidInterruptionLevel, err)
+
+	for _, score := range []float64{-0.1, 1.5} {
+		m = goapns.NewMessage().Body("body").RelevanceScore(score)
+		_, err = m.MarshalJSON()
+		assert.Equal(t, goapns.ErrorInvalidRelevanceScore, err)
+	}
+
+	for _, level := range []goapns.InterruptionLevel{goapns.InterruptionLevelPassive, goapns.InterruptionLevelActive, goapns.InterruptionLevelTimeSensitive, goapns.InterruptionLevelCritical} {
+		m = goapns.NewMessage().Body("body").InterruptionLevel(level)
+		assert.Nil(t, m.Payload.Validate())
+	}
+}
+
+func TestMessageCriticalSound(t *testing.T) {
+	m := goapns.NewMessage().Body("body").CriticalSound("alarm.caf", 0.8)
+	assert.Equal(t, goapns.NewCriticalSound("alarm.caf", 0.8), m.Payload.CriticalSound)
+
+	expected := []byte(`{"aps":{"alert":{"body":"body"},"sound":{"critical":1,"name":"alarm.caf","volume":0.8}}}`)
+	json, err := m.MarshalJSON()
+	assert.Nil(t, err)
+	assert.Equal(t, string(expected), string(json))
+
+	//Switching back to a regular sound uses the string form again.
+	m.Sound("default")
+	json, _ = m.MarshalJSON()
+	assert.Contains(t, string(json), `"sound":"default"`)
+	assert.Nil(t, m.Payload.CriticalSound)
+
+	//The Sound of the Payload is the name of the sound, like it always was.
+	m = goapns.NewMessage().Body("body")
+	m.Payload.Sound = "chime.caf"
+	json, _ = m.MarshalJSON()
+	assert.Contains(t, string(json), `"sound":"chime.caf"`)
+}
+
+func TestMessageCriticalSoundVolume(t *testing.T) {
+	for _, volume := range []float64{-0.1, 1.1} {
+		m := goapns.NewMessage().Body("body").CriticalSound("alarm.caf", volume)
+		_, err := m.MarshalJSON()
+		assert.Equal(t, goapns.ErrorInvalidSoundVolume, err)
+	}
+
+	m := goapns.NewMessage().Body("body").CriticalSound("alarm.caf", 0)
+	_, err := m.MarshalJSON()
+	assert.Nil(t, err)
+}
+
+func TestMessageInferredPushType(t *testing.T) {
+	assert.Equal(t, goapns.PushTypeAlert, goapns.NewMessage().Body("body").InferredPushType())
+	assert.Equal(t, goapns.PushTypeAlert, goapns.NewMessage().Body("body").ContentAvailable().InferredPushType())
+	assert.Equal(t, goapns.PushTypeAlert, goapns.NewMessage().Badge(1).ContentAvailable().InferredPushType())
+	assert.Equal(t, goapns.PushTypeBackground, goapns.NewMessage().ContentAvailable().InferredPushType())
+	assert.Equal(t, goapns.PushTypeLiveActivity, goapns.NewMessage().LiveActivity(goapns.LiveActivityEnd).InferredPushType())
+	assert.Equal(t, goapns.PushTypeVoIP, goapns.NewMessage().ContentAvailable().PushType(goapns.PushTypeVoIP).InferredPushType())
+}
+
+func TestMessageCustomKeys(t *testing.T) {
+	m := goapns.NewMessage().Body("body")
+	m.Custom("first", 1).Custom("second", "two")
+	m.CustomAt([]string{"match", "score"}, "2:1").CustomAt([]string{"match", "minute"}, 89)
+
+	expected := []byte(`{"aps":{"alert":{"body":"body"}},"first":1,"match":{"minute":89,"score":"2:1"},"second":"two"}`)
+	json, err := m.MarshalJSON()
+	assert.Nil(t, err)
+	assert.Equal(t, string(expected), string(json))
+
+	value, found := m.CustomValue("match", "score")
+	assert.True(t, found)
+	assert.Equal(t, "2:1", value)
+
+	_, found = m.CustomValue("match", "score", "home")
+	assert.False(t, found)
+	_, found = m.CustomValue("third")
+	assert.False(t, found)
+
+	m.DeleteCustom("match", "minute").DeleteCustom("first").DeleteCustom("nothing", "here")
+	expected = []byte(`{"aps":{"alert":{"body":"body"}},"match":{"score":"2:1"},"second":"two"}`)
+	json, _ = m.MarshalJSON()
+	assert.Equal(t, string(expected), string(json))
+}
+
+func TestMessageMergeCustom(t *testing.T) {
+	m := goapns.NewMessage().Body("body").CustomAt([]string{"match", "score"}, "1:1")
+
+	err := m.MergeCustom(map[string]interface{}{"match": map[string]interface{}{"minute": 90}, "id": "42"})
+	assert.Nil(t, err)
+
+	type article struct {
+		ArticleID int    `json:"article-id"`
+		Section   string `json:"section,omitempty"`
+	}
+	assert.Nil(t, m.MergeCustom(article{ArticleID: 7}))
+
+	expected := []byte(`{"aps":{"alert":{"body":"body"}},"article-id":7,"id":"42","match":{"minute":90,"score":"1:1"}}`)
+	json, _ := m.MarshalJSON()
+	assert.Equal(t, string(expected), string(json))
+
+	assert.Equal(t, goapns.ErrorCustomNotDictionary, m.MergeCustom([]string{"a"}))
+	assert.Equal(t, goapns.ErrorReservedCustomKey, m.MergeCustom(map[string]interface{}{"aps": 1, "other": 2}))
+	_, found := m.CustomValue("other")
+	assert.False(t, found)
+}
+
+func TestMessageCustomKeepsCallerMaps(t *testing.T) {
+	match := map[string]interface{}{"score": "1:1", "team": map[string]interface{}{"name": "home"}}
+	m := goapns.NewMessage().Custom("match", match)
+
+	m.CustomAt([]string{"match", "team", "coach"}, "Smith")
+	m.DeleteCustom("match", "score")
+	assert.Nil(t, m.MergeCustom(map[string]interface{}{"match": map[string]interface{}{"team": map[string]interface{}{"id": 7}}}))
+
+	//The map that was passed to Custom is not changed.
+	assert.Equal(t, map[string]interface{}{"score": "1:1", "team": map[string]interface{}{"name": "home"}}, match)
+
+	expected := []byte(`{"aps":{},"match":{"team":{"coach":"Smith","id":7,"name":"home"}}}`)
+	json, _ := m.MarshalJSON()
+	assert.Equal(t, string(expected), string(json))
+}
+
+func TestMessageCustomReservedKey(t *testing.T) {
+	m := goapns.NewMessage().Body("body").Custom("aps", map[string]interface{}{"alert": "hijacked"})
+	_, err := m.MarshalJSON()
+	assert.Equal(t, goapns.ErrorReservedCustomKey, err)
+	assert.Equal(t, goapns.ErrorReservedCustomKey, m.Validate())
+
+	m.DeleteCustom("aps")
+	_, err = m.MarshalJSON()
+	assert.Nil(t, err)
+}
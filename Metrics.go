@@ -0,0 +1,24 @@
+package goapns
+
+import "time"
+
+//Metrics is informed about every request a Connection makes to Apples servers.
+//Implement it to feed your monitoring system or use PrometheusMetrics.
+//The methods are called concurrently. To collect the metrics of a ConnectionPool,
+//set the same Metrics on every Connection you create.
+type Metrics interface {
+	//RequestStarted is called right before a request is sent.
+	RequestStarted()
+	//RequestFinished is called when a request that was started is done. The statusCode is 0
+	//and the reason empty if Apples servers could not be reached.
+	RequestFinished(statusCode int, reason string, duration time.Duration)
+	//Retried is called whenever a notification is sent again because of the RetryPolicy.
+	Retried()
+}
+
+//nopMetrics discards everything, it is used if no Metrics are set.
+type nopMetrics struct{}
+
+func (nopMetrics) RequestStarted()                                                       {}
+func (nopMetrics) RequestFinished(statusCode int, reason string, duration time.Duration) {}
+func (nopMetrics) Retried()                                                              {}
@@ -0,0 +1,54 @@
+package goapns_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tantalum73/Go-APNS"
+)
+
+func TestPrometheusMetrics(t *testing.T) {
+	conn := mockConnection(t)
+	tokens := testTokens(4)
+	metrics := goapns.NewPrometheusMetrics(0.5, 10)
+	conn.Metrics = metrics
+	conn.RetryPolicy = goapns.NewRetryPolicy()
+	conn.RetryPolicy.BaseBackoff = time.Millisecond
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/3/device/" + tokens[2]:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"reason":"BadTopic"}`))
+		case "/3/device/" + tokens[3]:
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"reason":"ServiceUnavailable"}`))
+		}
+	}))
+	defer server.Close()
+	conn.Host = server.URL
+
+	for _, token := range []string{tokens[1], tokens[1], tokens[2], tokens[3]} {
+		conn.Send(context.Background(), mockMessage(), token)
+	}
+
+	recorder := httptest.NewRecorder()
+	metrics.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := ioutil.ReadAll(recorder.Body)
+	output := string(body)
+
+	assert.Contains(t, recorder.Header().Get("Content-Type"), "version=0.0.4")
+	assert.Contains(t, output, `goapns_requests_total{status="200",reason=""} 2`)
+	assert.Contains(t, output, `goapns_requests_total{status="400",reason="BadTopic"} 1`)
+	assert.Contains(t, output, `goapns_requests_total{status="503",reason="ServiceUnavailable"} 3`)
+	assert.Contains(t, output, `goapns_request_duration_seconds_bucket{le="10"} 6`)
+	assert.Contains(t, output, `goapns_request_duration_seconds_bucket{le="+Inf"} 6`)
+	assert.Contains(t, output, "goapns_request_duration_seconds_count 6")
+	assert.Contains(t, output, "goapns_requests_in_flight 0")
+	assert.Contains(t, output, "goapns_retries_total 2")
+}
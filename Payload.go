@@ -1,5 +1,30 @@
 package goapns
 
+import "errors"
+
+var (
+	//ErrorInvalidInterruptionLevel is returned if the interruption level is not one of the levels Apple knows.
+	ErrorInvalidInterruptionLevel = errors.New("The interruption-level must be passive, active, time-sensitive or critical.")
+	//ErrorInvalidRelevanceScore is returned if the relevance score is not between 0.0 and 1.0.
+	ErrorInvalidRelevanceScore = errors.New("The relevance-score must be between 0.0 and 1.0.")
+)
+
+//InterruptionLevel indicates the importance and delivery timing of a notification.
+type InterruptionLevel string
+
+//The interruption levels that are supported by Apple.
+const (
+	//InterruptionLevelPassive adds the notification to the notification list without lighting up the screen or playing a sound.
+	InterruptionLevelPassive InterruptionLevel = "passive"
+	//InterruptionLevelActive presents the notification immediately, lights up the screen, and can play a sound. It is the default.
+	InterruptionLevelActive InterruptionLevel = "active"
+	//InterruptionLevelTimeSensitive presents the notification immediately and it can break through system controls such as Focus.
+	InterruptionLevelTimeSensitive InterruptionLevel = "time-sensitive"
+	//InterruptionLevelCritical presents the notification immediately, lights up the screen and bypasses the mute switch to play a sound.
+	//Your app needs the critical alerts entitlement for it.
+	InterruptionLevelCritical InterruptionLevel = "critical"
+)
+
 //Payload defines properties as they are described as in Apples documentation.
 //Badge, Sound, ContentAvailable and Category are those.
 //Think of Payload as a meta-object to your notification as it specify the behaviour
@@ -16,6 +41,10 @@ type Payload struct {
 	//The audio must be in one of the audio data formats that are compatible with system sounds.
 	Sound string
 
+	//CriticalSound is a sound that is played even if the device is muted or in Do Not Disturb.
+	//If it is set, it is sent instead of Sound.
+	CriticalSound *CriticalSound
+
 	//ContentAvailable: if this key is provided with a value of 1 to indicate that new content is available.
 	//Including this key and value means that when your app is launched in the background or resumed,
 	//application:didReceiveRemoteNotification:fetchCompletionHandler: is called.
@@ -28,14 +57,63 @@ type Payload struct {
 	// MutabelContent specifies if the app is allowed to mutate the notification before it gets presented.
 	//If so, your notification extension will be woken up to do the job.
 	MutableContent int
+
+	//ThreadID is an app-specific identifier for grouping related notifications.
+	ThreadID string
+
+	//InterruptionLevel indicates the importance and delivery timing of a notification.
+	//Use one of InterruptionLevelPassive, InterruptionLevelActive, InterruptionLevelTimeSensitive
+	//or InterruptionLevelCritical. If it is empty, the system uses active.
+	InterruptionLevel InterruptionLevel
+
+	//RelevanceScore is a number between 0.0 and 1.0 that the system uses to sort the notifications from your app.
+	//The highest score gets featured in the notification summary.
+	//It is omitted if it is nil.
+	RelevanceScore *float64
+
+	//TargetContentID is the identifier of the window brought forward when the notification is opened.
+	TargetContentID string
+
+	//FilterCriteria is the criteria the system evaluates to determine if it displays the notification in the current Focus.
+	FilterCriteria string
+
+	//LiveActivity is set if the notification starts, updates or ends a live activity. It is nil otherwise.
+	LiveActivity *LiveActivity
 }
 
-//NewPayload provides a initializer of Payload with empty values and no badge.
+//NewPayload provides a initializer of Payload with empty values, no badge and no relevance score.
 func NewPayload() Payload {
-	p := Payload{-1, "", 0, "", 0}
+	p := Payload{Badge: -1}
 	return p
 }
 
+//Validate checks that the values of the Payload are allowed by Apple.
+//It returns ErrorInvalidInterruptionLevel, ErrorInvalidRelevanceScore, ErrorInvalidSoundVolume
+//or the error of an invalid LiveActivity if not.
+func (p *Payload) Validate() error {
+	if p.CriticalSound != nil {
+		if err := p.CriticalSound.Validate(); err != nil {
+			return err
+		}
+	}
+	if p.LiveActivity != nil {
+		if err := p.LiveActivity.Validate(); err != nil {
+			return err
+		}
+	}
+
+	switch p.InterruptionLevel {
+	case "", InterruptionLevelPassive, InterruptionLevelActive, InterruptionLevelTimeSensitive, InterruptionLevelCritical:
+	default:
+		return ErrorInvalidInterruptionLevel
+	}
+
+	if p.RelevanceScore != nil && (*p.RelevanceScore < 0 || *p.RelevanceScore > 1) {
+		return ErrorInvalidRelevanceScore
+	}
+	return nil
+}
+
 //MapInto is passed in a map on which the Payload content is appended to.
 //It return a new map with every property and key set, ready to build a JSON from it.
 func (p *Payload) MapInto(mapped map[string]interface{}) map[string]interface{} {
@@ -45,7 +123,9 @@ func (p *Payload) MapInto(mapped map[string]interface{}) map[string]interface{}
 		//and therefore the badge on the app is unchanged
 		mapped["badge"] = p.Badge
 	}
-	if p.Sound != "" {
+	if p.CriticalSound != nil {
+		mapped["sound"] = p.CriticalSound
+	} else if p.Sound != "" {
 		mapped["sound"] = p.Sound
 	}
 	if p.ContentAvailable != 0 {
@@ -57,5 +137,24 @@ func (p *Payload) MapInto(mapped map[string]interface{}) map[string]interface{}
 	if p.Category != "" {
 		mapped["category"] = p.Category
 	}
+	if p.ThreadID != "" {
+		mapped["thread-id"] = p.ThreadID
+	}
+	if p.InterruptionLevel != "" {
+		mapped["interruption-level"] = p.InterruptionLevel
+	}
+	if p.RelevanceScore != nil {
+		//Only set the relevance score if the user specified one.
+		mapped["relevance-score"] = *p.RelevanceScore
+	}
+	if p.TargetContentID != "" {
+		mapped["target-content-id"] = p.TargetContentID
+	}
+	if p.FilterCriteria != "" {
+		mapped["filter-criteria"] = p.FilterCriteria
+	}
+	if p.LiveActivity != nil {
+		mapped = p.LiveActivity.MapInto(mapped)
+	}
 	return mapped
 }
@@ -0,0 +1,92 @@
+package goapns
+
+//PluralRule returns the index of the plural form that is used for the count n.
+//The forms are passed to the plural function of a Template in the order the
+//language defines them, for example singular and plural in English.
+type PluralRule func(n int) int
+
+//Plural rules of common languages, they follow the cardinal rules of the Unicode CLDR for integers.
+var (
+	//PluralRuleOne distinguishes one and other, it is used for English, German and most other languages.
+	PluralRuleOne PluralRule = func(n int) int {
+		if n == 1 {
+			return 0
+		}
+		return 1
+	}
+	//PluralRuleZeroOne distinguishes zero or one and other, it is used for French and Portuguese.
+	PluralRuleZeroOne PluralRule = func(n int) int {
+		if n == 0 || n == 1 {
+			return 0
+		}
+		return 1
+	}
+	//PluralRuleNone has only one form, it is used for Chinese, Japanese and Korean.
+	PluralRuleNone PluralRule = func(n int) int {
+		return 0
+	}
+	//PluralRuleEastSlavic distinguishes one, few and many, it is used for Russian and Ukrainian.
+	PluralRuleEastSlavic PluralRule = func(n int) int {
+		switch {
+		case n%10 == 1 && n%100 != 11:
+			return 0
+		case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+			return 1
+		}
+		return 2
+	}
+	//PluralRulePolish distinguishes one, few and many.
+	PluralRulePolish PluralRule = func(n int) int {
+		switch {
+		case n == 1:
+			return 0
+		case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+			return 1
+		}
+		return 2
+	}
+	//PluralRuleCzech distinguishes one, few (2 - 4) and other, it is used for Czech and Slovak.
+	PluralRuleCzech PluralRule = func(n int) int {
+		switch {
+		case n == 1:
+			return 0
+		case n >= 2 && n <= 4:
+			return 1
+		}
+		return 2
+	}
+)
+
+//pluralRules maps languages to their PluralRule. Languages that are missing use PluralRuleOne.
+var pluralRules = map[string]PluralRule{
+	"fr": PluralRuleZeroOne,
+	"pt": PluralRuleZeroOne,
+	"zh": PluralRuleNone,
+	"ja": PluralRuleNone,
+	"ko": PluralRuleNone,
+	"th": PluralRuleNone,
+	"vi": PluralRuleNone,
+	"id": PluralRuleNone,
+	"ru": PluralRuleEastSlavic,
+	"uk": PluralRuleEastSlavic,
+	"be": PluralRuleEastSlavic,
+	"pl": PluralRulePolish,
+	"cs": PluralRuleCzech,
+	"sk": PluralRuleCzech,
+}
+
+//plural returns the form for the count. If there are fewer forms than the rule
+//expects, the last one is used.
+func plural(rule PluralRule, n int, forms []string) string {
+	if len(forms) == 0 {
+		return ""
+	}
+	index := rule(n)
+	if index < 0 {
+		index = 0
+	}
+	if index >= len(forms) {
+		index = len(forms) - 1
+	}
+	return forms[index]
+}
@@ -0,0 +1,144 @@
+package goapns
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//DefaultLatencyBuckets are the upper bounds in seconds of the latency histogram of PrometheusMetrics.
+var DefaultLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+//PrometheusMetrics collects Metrics and exposes them in the Prometheus text format.
+//It is an http.Handler, register it at the path your Prometheus scrapes:
+//
+//	metrics := goapns.NewPrometheusMetrics()
+//	conn.Metrics = metrics
+//	http.Handle("/metrics", metrics)
+//
+//The following metrics are exposed:
+//goapns_requests_total by status and reason, goapns_request_duration_seconds,
+//goapns_requests_in_flight and goapns_retries_total.
+type PrometheusMetrics struct {
+	mutex    sync.Mutex
+	requests map[requestLabels]uint64
+	buckets  []float64
+	counts   []uint64
+	sum      float64
+	count    uint64
+	inFlight int64
+	retries  uint64
+}
+
+//requestLabels are the labels of goapns_requests_total.
+type requestLabels struct {
+	status int
+	reason string
+}
+
+//NewPrometheusMetrics creates PrometheusMetrics with a latency histogram of the buckets
+//in seconds. DefaultLatencyBuckets are used if you pass none.
+func NewPrometheusMetrics(buckets ...float64) *PrometheusMetrics {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+
+	return &PrometheusMetrics{
+		requests: make(map[requestLabels]uint64),
+		buckets:  sorted,
+		counts:   make([]uint64, len(sorted)),
+	}
+}
+
+//RequestStarted increases the number of requests in flight.
+func (p *PrometheusMetrics) RequestStarted() {
+	p.mutex.Lock()
+	p.inFlight++
+	p.mutex.Unlock()
+}
+
+//RequestFinished counts the request and observes its duration.
+func (p *PrometheusMetrics) RequestFinished(statusCode int, reason string, duration time.Duration) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.inFlight--
+	p.requests[requestLabels{statusCode, reason}]++
+
+	seconds := duration.Seconds()
+	for i, bound := range p.buckets {
+		if seconds <= bound {
+			p.counts[i]++
+		}
+	}
+	p.sum += seconds
+	p.count++
+}
+
+//Retried counts the retry.
+func (p *PrometheusMetrics) Retried() {
+	p.mutex.Lock()
+	p.retries++
+	p.mutex.Unlock()
+}
+
+//ServeHTTP writes the metrics in the Prometheus text format.
+func (p *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	p.WriteTo(w)
+}
+
+//WriteTo writes the metrics in the Prometheus text format to w.
+func (p *PrometheusMetrics) WriteTo(w io.Writer) (int64, error) {
+	p.mutex.Lock()
+	var b strings.Builder
+
+	labels := make([]requestLabels, 0, len(p.requests))
+	for label := range p.requests {
+		labels = append(labels, label)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		if labels[i].status != labels[j].status {
+			return labels[i].status < labels[j].status
+		}
+		return labels[i].reason < labels[j].reason
+	})
+
+	b.WriteString("# HELP goapns_requests_total Requests sent to Apples servers by status code and reason.\n")
+	b.WriteString("# TYPE goapns_requests_total counter\n")
+	for _, label := range labels {
+		fmt.Fprintf(&b, "goapns_requests_total{status=\"%d\",reason=\"%s\"} %d\n", label.status, escapeLabel(label.reason), p.requests[label])
+	}
+
+	b.WriteString("# HELP goapns_request_duration_seconds Latency of requests to Apples servers.\n")
+	b.WriteString("# TYPE goapns_request_duration_seconds histogram\n")
+	for i, bound := range p.buckets {
+		fmt.Fprintf(&b, "goapns_request_duration_seconds_bucket{le=\"%v\"} %d\n", bound, p.counts[i])
+	}
+	fmt.Fprintf(&b, "goapns_request_duration_seconds_bucket{le=\"+Inf\"} %d\n", p.count)
+	fmt.Fprintf(&b, "goapns_request_duration_seconds_sum %v\n", p.sum)
+	fmt.Fprintf(&b, "goapns_request_duration_seconds_count %d\n", p.count)
+
+	b.WriteString("# HELP goapns_requests_in_flight Requests that wait for a response of Apples servers.\n")
+	b.WriteString("# TYPE goapns_requests_in_flight gauge\n")
+	fmt.Fprintf(&b, "goapns_requests_in_flight %d\n", p.inFlight)
+
+	b.WriteString("# HELP goapns_retries_total Notifications that were sent again.\n")
+	b.WriteString("# TYPE goapns_retries_total counter\n")
+	fmt.Fprintf(&b, "goapns_retries_total %d\n", p.retries)
+	p.mutex.Unlock()
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+//escapeLabel escapes a label value as the Prometheus text format requires it.
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
@@ -0,0 +1,312 @@
+package goapns
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+//ErrorQueueClosed is returned if a Message is enqueued after the Queue was closed.
+var ErrorQueueClosed = errors.New("The queue is closed and does not accept new notifications.")
+
+//DefaultQueueWorkers is the number of workers NewQueue starts if you pass a value <= 0.
+const DefaultQueueWorkers = 16
+
+//DefaultQueueMaxAttempts is the number of attempts a Queue makes to send a job, see Queue.MaxAttempts.
+const DefaultQueueMaxAttempts = 10
+
+//Backoff of jobs that failed with an error that is worth another attempt.
+//It doubles with every attempt up to queueMaxRetryBackoff.
+const (
+	queueRetryBackoff    = 100 * time.Millisecond
+	queueMaxRetryBackoff = time.Minute
+)
+
+//Queue sends notifications at least once, even if your process restarts in between.
+//Every token is stored as QueueJob in the QueueStore before Enqueue returns
+//and acknowledged once its final Response was delivered. Jobs that were not acknowledged
+//are sent again by the next Queue that is created with the same store.
+//A notification can therefore be sent twice if the process stops between the request and
+//the acknowledgement, set an APNSID on the Header to recognize duplicates.
+//
+//A job is acknowledged once it was sent or failed with an error that will not go away,
+//like ErrorBadDeviceToken. Network errors and errors that DefaultRetryable, ErrorRateLimited
+//or ErrorDeferred report are worth another attempt: the job stays in the QueueStore and is
+//sent again after a backoff, a deferred one when the quiet hours end. After MaxAttempts attempts
+//the job is acknowledged and the Response of the last attempt is final. A notification that
+//was deferred to the Scheduler of a QuietHoursPolicy is acknowledged, the Scheduler sends it.
+//Jobs that wait for another attempt when the Queue is closed are sent by the next Queue on the store.
+//
+//You get the final Response of every token on the Responses() channel. Make sure to read
+//from it, otherwise the workers block and no job is acknowledged.
+type Queue struct {
+	sender    Sender
+	store     QueueStore
+	responses chan Response
+
+	//ctx is cancelled by Close, it aborts the requests that are in flight.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	//mutex guards pending, closed and maxAttempts, ready is signaled when one of the first two changes.
+	mutex       sync.Mutex
+	ready       *sync.Cond
+	pending     []queueItem
+	closed      bool
+	maxAttempts int
+	workers     sync.WaitGroup
+}
+
+//queueItem is a job waiting for a worker together with the number of attempts
+//that were made to send it since the Queue was created.
+type queueItem struct {
+	job      QueueJob
+	attempts int
+}
+
+//NewQueue creates a Queue that sends through the given Connection or ConnectionPool,
+//resumes the pending jobs of the store and starts workers to send them concurrently.
+//It returns an error if the pending jobs can not be read.
+//It will return a *Queue or an error. One of this is always nil.
+func NewQueue(sender Sender, store QueueStore, workers int) (*Queue, error) {
+	if workers <= 0 {
+		workers = DefaultQueueWorkers
+	}
+
+	jobs, err := store.Pending()
+	if err != nil {
+		return nil, err
+	}
+
+	q := &Queue{
+		sender:      sender,
+		store:       store,
+		responses:   make(chan Response, workers),
+		maxAttempts: DefaultQueueMaxAttempts,
+	}
+	q.ctx, q.cancel = context.WithCancel(context.Background())
+	q.ready = sync.NewCond(&q.mutex)
+	for _, job := range jobs {
+		q.pending = append(q.pending, queueItem{job: job})
+	}
+
+	q.workers.Add(workers)
+	for i := 0; i < workers; i++ {
+		go q.work()
+	}
+	return q, nil
+}
+
+//Responses returns the channel that receives one Response for every job.
+//It is closed after Close() was called and the workers are done.
+func (q *Queue) Responses() <-chan Response {
+	return q.responses
+}
+
+//Enqueue stores the Message for every token and queues it for sending.
+//When it returns without error, the notifications are durable in the QueueStore.
+//It returns the error of Message.Envelope or of the store, nothing is queued in this case.
+func (q *Queue) Enqueue(message *Message, tokens ...string) error {
+	envelope, err := message.Envelope()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	//The jobs are sent from the Envelope, like after a restart,
+	//so changes to the Message after Enqueue do not reach them.
+	items := make([]queueItem, len(tokens))
+	jobs := make([]QueueJob, len(tokens))
+	for i, token := range tokens {
+		jobs[i] = QueueJob{ID: newID(), Envelope: envelope, Token: token, EnqueuedAt: now}
+		items[i] = queueItem{job: jobs[i]}
+	}
+
+	q.mutex.Lock()
+	closed := q.closed
+	q.mutex.Unlock()
+	if closed {
+		return ErrorQueueClosed
+	}
+
+	//Writing to the store may take a while, the workers keep sending meanwhile.
+	if err := q.store.Append(jobs...); err != nil {
+		return err
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if !q.closed {
+		//If the Queue was closed in between, the jobs are durable and sent by the next Queue.
+		q.pending = append(q.pending, items...)
+		q.ready.Broadcast()
+	}
+	return nil
+}
+
+//MaxAttempts sets the number of attempts that are made to send a job, including the first one.
+//The attempts are counted since the Queue was created, a job that is resumed after a restart
+//starts over. A value <= 0 sets DefaultQueueMaxAttempts.
+func (q *Queue) MaxAttempts(attempts int) *Queue {
+	if attempts <= 0 {
+		attempts = DefaultQueueMaxAttempts
+	}
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.maxAttempts = attempts
+	return q
+}
+
+//Push stores the Message for every token and queues it for sending, like Enqueue.
+func (q *Queue) Push(message *Message, tokens []string) error {
+	return q.Enqueue(message, tokens...)
+}
+
+//Len returns the number of jobs that are waiting for a worker.
+func (q *Queue) Len() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return len(q.pending)
+}
+
+//Close stops accepting new notifications, aborts the requests that are in flight and waits
+//until the workers are done. Jobs that are still waiting or were aborted stay in the QueueStore
+//and are sent by the next Queue. Afterwards the Responses() channel is closed.
+//The QueueStore is not closed, it belongs to you.
+func (q *Queue) Close() {
+	q.mutex.Lock()
+	if q.closed {
+		q.mutex.Unlock()
+		return
+	}
+	q.closed = true
+	q.ready.Broadcast()
+	q.mutex.Unlock()
+
+	q.cancel()
+	q.workers.Wait()
+	close(q.responses)
+}
+
+//work sends jobs until the Queue is closed.
+func (q *Queue) work() {
+	defer q.workers.Done()
+
+	for {
+		item, ok := q.next()
+		if !ok {
+			return
+		}
+		if response, final := q.deliver(item); final {
+			q.responses <- response
+		}
+	}
+}
+
+//next waits for a job and returns it. It returns false if the Queue was closed.
+func (q *Queue) next() (queueItem, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for len(q.pending) == 0 && !q.closed {
+		q.ready.Wait()
+	}
+	if q.closed {
+		return queueItem{}, false
+	}
+
+	item := q.pending[0]
+	q.pending[0] = queueItem{}
+	q.pending = q.pending[1:]
+	return item, true
+}
+
+//deliver sends the job and acknowledges it if the Response is final or the job has no attempts left.
+//Otherwise the job is queued again after a backoff and false is returned. If the acknowledgement fails, the error
+//of the store is set on the Response unless it has an error already, the job is sent again after a restart then.
+func (q *Queue) deliver(item queueItem) (Response, bool) {
+	message, err := item.job.Envelope.Message()
+	if err != nil {
+		//A job that can not be read will never be sent, it is dropped.
+		q.store.Ack(item.job.ID)
+		return newErrorResponse(nil, item.job.Token, err), true
+	}
+
+	var response Response
+	if encoded, err := message.encode(); err != nil {
+		response = newErrorResponse(message, item.job.Token, err)
+	} else {
+		response = senderOf(q.sender).send(q.ctx, message, encoded, item.job.Token)
+	}
+	if q.ctx.Err() != nil {
+		//Close aborted the request, the job is sent by the next Queue.
+		return response, false
+	}
+
+	item.attempts++
+	q.mutex.Lock()
+	exhausted := item.attempts >= q.maxAttempts
+	q.mutex.Unlock()
+	if retry, at := q.retry(response, item.attempts-1); retry && !exhausted {
+		q.requeue(item, at)
+		return response, false
+	}
+
+	if err := q.store.Ack(item.job.ID); err != nil && response.Error == nil {
+		response.Error = err
+	}
+	return response, true
+}
+
+//retry returns true and the delay after which the job is sent again if the Response is not final.
+func (q *Queue) retry(response Response, attempts int) (bool, time.Duration) {
+	backoff := queueRetryBackoff
+	for i := 0; i < attempts && backoff < queueMaxRetryBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > queueMaxRetryBackoff {
+		backoff = queueMaxRetryBackoff
+	}
+
+	switch {
+	case response.Error == nil:
+		return false, 0
+	case response.Error == ErrorDeferred:
+		if response.ScheduleID != "" {
+			//The Scheduler of the QuietHoursPolicy sends it.
+			return false, 0
+		}
+		if until := time.Until(response.DeferredUntil); until > backoff {
+			return true, until
+		}
+		return true, backoff
+	case response.Error == ErrorRateLimited:
+		return true, backoff
+	case DefaultRetryable(response):
+		return true, backoff
+	}
+	return false, 0
+}
+
+//requeue adds the item to the pending jobs after the delay, unless the Queue was closed.
+//It stays in the QueueStore in any case.
+func (q *Queue) requeue(item queueItem, delay time.Duration) {
+	time.AfterFunc(delay, func() {
+		q.mutex.Lock()
+		defer q.mutex.Unlock()
+		if !q.closed {
+			q.pending = append(q.pending, item)
+			q.ready.Broadcast()
+		}
+	})
+}
+
+//newID creates a random ID for a QueueJob or a Schedule.
+func newID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,294 @@
+package goapns
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+//ErrorQueueCorrupted is returned by NewFileQueueStore if the log contains a record that can not be read.
+//Only the last record may be incomplete, it is discarded because the process stopped while writing it.
+var ErrorQueueCorrupted = errors.New("The queue log contains a record that can not be read.")
+
+//QueueJob is a notification to one device token that is stored in a QueueStore until it is acknowledged.
+type QueueJob struct {
+	//ID identifies the job in the QueueStore.
+	ID string `json:"id"`
+	//Envelope is the complete Message, including its Header.
+	Envelope Envelope `json:"envelope"`
+	//Token is the device token the Message is sent to.
+	Token string `json:"token"`
+	//EnqueuedAt is the time at which the job was added to the Queue.
+	EnqueuedAt time.Time `json:"enqueued-at"`
+}
+
+//QueueStore keeps the jobs of a Queue until they are acknowledged, so they survive a restart.
+//MemoryQueueStore and FileQueueStore are provided, implement it to use your database.
+//Implementations must be safe for concurrent use.
+type QueueStore interface {
+	//Append stores the jobs. When it returns without error, the jobs must be durable.
+	Append(jobs ...QueueJob) error
+	//Ack removes the job because it was delivered. Nothing happens if it is not known.
+	Ack(id string) error
+	//Pending returns every job that was not acknowledged, in the order they were appended.
+	Pending() ([]QueueJob, error)
+	//Close releases the resources of the store.
+	Close() error
+}
+
+//MemoryQueueStore is a QueueStore that keeps the jobs in memory.
+//They do not survive a restart, use it for tests or as cache of another store.
+type MemoryQueueStore struct {
+	mutex    sync.RWMutex
+	jobs     map[string]memoryQueueJob
+	sequence uint64
+}
+
+//memoryQueueJob remembers the order in which the job was appended.
+type memoryQueueJob struct {
+	job      QueueJob
+	sequence uint64
+}
+
+//NewMemoryQueueStore creates an empty MemoryQueueStore.
+func NewMemoryQueueStore() *MemoryQueueStore {
+	return &MemoryQueueStore{jobs: make(map[string]memoryQueueJob)}
+}
+
+//Append stores the jobs.
+func (s *MemoryQueueStore) Append(jobs ...QueueJob) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, job := range jobs {
+		s.sequence++
+		s.jobs[job.ID] = memoryQueueJob{job: job, sequence: s.sequence}
+	}
+	return nil
+}
+
+//Ack removes the job. Nothing happens if it is not known.
+func (s *MemoryQueueStore) Ack(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.jobs, id)
+	return nil
+}
+
+//Pending returns every job that was not acknowledged, in the order they were appended.
+func (s *MemoryQueueStore) Pending() ([]QueueJob, error) {
+	s.mutex.RLock()
+	stored := make([]memoryQueueJob, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		stored = append(stored, job)
+	}
+	s.mutex.RUnlock()
+	sort.Slice(stored, func(i, j int) bool { return stored[i].sequence < stored[j].sequence })
+
+	jobs := make([]QueueJob, len(stored))
+	for i, job := range stored {
+		jobs[i] = job.job
+	}
+	return jobs, nil
+}
+
+//Close does nothing, the jobs stay in memory.
+func (s *MemoryQueueStore) Close() error {
+	return nil
+}
+
+//queueCompactionThreshold is the number of acknowledgements after which
+//a FileQueueStore rewrites its log if they outnumber the pending jobs.
+const queueCompactionThreshold = 1024
+
+//FileQueueStore is a QueueStore that writes every change to a log file (write-ahead log)
+//and syncs it to disk before it returns. The jobs are kept in memory as well.
+//When it is opened, the log is replayed and rewritten with the pending jobs only.
+//It is also rewritten when it grows because of many acknowledgements.
+type FileQueueStore struct {
+	path string
+
+	//mutex serializes writing to the log.
+	mutex  sync.Mutex
+	file   *os.File
+	acked  int
+	memory *MemoryQueueStore
+}
+
+//queueRecord is one line of the log of a FileQueueStore.
+type queueRecord struct {
+	Job *QueueJob `json:"job,omitempty"`
+	Ack string    `json:"ack,omitempty"`
+}
+
+//NewFileQueueStore opens the FileQueueStore that is stored at path or creates it.
+//It returns ErrorQueueCorrupted if the log can not be read.
+//It will return a *FileQueueStore or an error. One of this is always nil.
+func NewFileQueueStore(path string) (*FileQueueStore, error) {
+	s := &FileQueueStore{path: path, memory: NewMemoryQueueStore()}
+
+	file, err := os.Open(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		err = s.replay(file)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.compact(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+//Append writes the jobs to the log and syncs it.
+func (s *FileQueueStore) Append(jobs ...QueueJob) error {
+	records := make([]queueRecord, len(jobs))
+	for i := range jobs {
+		records[i].Job = &jobs[i]
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.write(records...); err != nil {
+		return err
+	}
+	return s.memory.Append(jobs...)
+}
+
+//Ack writes the acknowledgement to the log and syncs it. Nothing happens if the job is not known.
+func (s *FileQueueStore) Ack(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.memory.mutex.RLock()
+	_, found := s.memory.jobs[id]
+	s.memory.mutex.RUnlock()
+	if !found {
+		return nil
+	}
+
+	if err := s.write(queueRecord{Ack: id}); err != nil {
+		return err
+	}
+	s.memory.Ack(id)
+
+	s.acked++
+	if s.acked >= queueCompactionThreshold && s.acked > len(s.memory.jobs) {
+		return s.compact()
+	}
+	return nil
+}
+
+//Pending returns every job that was not acknowledged, in the order they were appended.
+func (s *FileQueueStore) Pending() ([]QueueJob, error) {
+	return s.memory.Pending()
+}
+
+//Close closes the log file.
+func (s *FileQueueStore) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+//replay applies every record of the log to memory.
+func (s *FileQueueStore) replay(log io.Reader) error {
+	reader := bufio.NewReader(log)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		complete := err == nil
+
+		if len(bytes.TrimSpace(line)) > 0 {
+			var record queueRecord
+			if jsonErr := json.Unmarshal(line, &record); jsonErr != nil {
+				if !complete {
+					//The process stopped while the last record was written, it was never acknowledged to the caller.
+					return nil
+				}
+				return ErrorQueueCorrupted
+			}
+			if record.Job != nil {
+				s.memory.Append(*record.Job)
+			} else {
+				s.memory.Ack(record.Ack)
+			}
+		}
+
+		if !complete {
+			return nil
+		}
+	}
+}
+
+//compact rewrites the log with the pending jobs and opens it for appending.
+//The caller must hold the mutex, if the store is in use already.
+func (s *FileQueueStore) compact() error {
+	jobs, _ := s.memory.Pending()
+
+	var log bytes.Buffer
+	for i := range jobs {
+		line, err := json.Marshal(queueRecord{Job: &jobs[i]})
+		if err != nil {
+			return err
+		}
+		log.Write(line)
+		log.WriteByte('\n')
+	}
+	if err := writeFileAtomically(s.path, log.Bytes()); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	if s.file != nil {
+		s.file.Close()
+	}
+	s.file = file
+	s.acked = 0
+	return nil
+}
+
+//write appends the records to the log and syncs it. The caller must hold the mutex.
+func (s *FileQueueStore) write(records ...queueRecord) error {
+	if s.file == nil {
+		return os.ErrClosed
+	}
+
+	var log bytes.Buffer
+	for _, record := range records {
+		line, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		log.Write(line)
+		log.WriteByte('\n')
+	}
+	if _, err := s.file.Write(log.Bytes()); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
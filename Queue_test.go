@@ -0,0 +1,247 @@
+package goapns_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tantalum73/Go-APNS"
+	"github.com/tantalum73/Go-APNS/goapnstest"
+)
+
+func TestQueueDelivers(t *testing.T) {
+	conn := mockConnection(t)
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+	}))
+	defer server.Close()
+	conn.Host = server.URL
+
+	store := goapns.NewMemoryQueueStore()
+	queue, err := goapns.NewQueue(conn, store, 4)
+	assert.Nil(t, err)
+
+	tokens := testTokens(20)
+	assert.Nil(t, queue.Push(mockMessage(), tokens))
+
+	for i := 0; i < len(tokens); i++ {
+		response := <-queue.Responses()
+		assert.True(t, response.Sent())
+		assert.Nil(t, response.Error)
+	}
+	queue.Close()
+
+	assert.Equal(t, int32(len(tokens)), atomic.LoadInt32(&requests))
+	pending, _ := store.Pending()
+	assert.Empty(t, pending)
+	assert.Equal(t, goapns.ErrorQueueClosed, queue.Enqueue(mockMessage(), tokens[0]))
+	_, open := <-queue.Responses()
+	assert.False(t, open)
+}
+
+func TestQueueRetries(t *testing.T) {
+	conn := mockConnection(t)
+	tokens := testTokens(2)
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, tokens[1]):
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"reason":"BadDeviceToken"}`))
+		case atomic.AddInt32(&requests, 1) == 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+	conn.Host = server.URL
+
+	store := goapns.NewMemoryQueueStore()
+	queue, err := goapns.NewQueue(conn, store, 1)
+	assert.Nil(t, err)
+	defer queue.Close()
+
+	//The first attempt fails with a retryable error, only the final Response is delivered.
+	assert.Nil(t, queue.Enqueue(mockMessage(), tokens[0]))
+	response := <-queue.Responses()
+	assert.True(t, response.Sent())
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+
+	//Permanent errors are final.
+	assert.Nil(t, queue.Enqueue(mockMessage(), tokens[1]))
+	response = <-queue.Responses()
+	assert.Equal(t, goapns.ErrorBadDeviceToken, response.Error)
+
+	pending, _ := store.Pending()
+	assert.Empty(t, pending)
+}
+
+func TestQueueMaxAttempts(t *testing.T) {
+	server := goapnstest.NewServer()
+	defer server.Close()
+	certificate, err := goapns.CertificateFromP12("example/certificate-valid-encrypted.p12", "password")
+	assert.Nil(t, err)
+	conn := server.Connection(certificate)
+
+	//The server fails every attempt.
+	token := testTokens(1)[0]
+	replies := make([]goapnstest.Reply, 10)
+	for i := range replies {
+		replies[i] = goapnstest.NewReply("InternalServerError")
+	}
+	server.Script(token, replies...)
+
+	store := goapns.NewMemoryQueueStore()
+	queue, err := goapns.NewQueue(conn, store, 1)
+	assert.Nil(t, err)
+	defer queue.Close()
+	queue.MaxAttempts(3)
+
+	assert.Nil(t, queue.Enqueue(mockMessage(), token))
+	response := <-queue.Responses()
+	assert.Equal(t, goapns.ErrorInternalServerError, response.Error)
+	assert.Len(t, server.Notifications(), 3)
+
+	pending, _ := store.Pending()
+	assert.Empty(t, pending)
+}
+
+func TestQueueCloseAbortsRequests(t *testing.T) {
+	conn, _, stop := recordingConnection(t)
+	defer stop()
+	conn.RateLimiter = goapns.NewRateLimiter(goapns.PerHour(1))
+
+	store := goapns.NewMemoryQueueStore()
+	queue, err := goapns.NewQueue(conn, store, 1)
+	assert.Nil(t, err)
+
+	//The second notification waits an hour for the RateLimiter.
+	token := testTokens(1)[0]
+	assert.Nil(t, queue.Enqueue(mockMessage(), token, token))
+	response := <-queue.Responses()
+	assert.True(t, response.Sent())
+
+	closed := make(chan struct{})
+	go func() {
+		queue.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close waited for the RateLimiter")
+	}
+
+	pending, _ := store.Pending()
+	assert.Len(t, pending, 1)
+}
+
+func TestQueueSendsEnvelope(t *testing.T) {
+	conn := mockConnection(t)
+	received := make(chan *http.Request, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r
+	}))
+	defer server.Close()
+	conn.Host = server.URL
+
+	queue, err := goapns.NewQueue(conn, goapns.NewMemoryQueueStore(), 1)
+	assert.Nil(t, err)
+	defer queue.Close()
+
+	//Changing the Message after it was enqueued does not change the notification.
+	message := mockMessage().Topic("com.example.app")
+	assert.Nil(t, queue.Enqueue(message, testToken))
+	message.Topic("com.example.other")
+
+	response := <-queue.Responses()
+	assert.True(t, response.Sent())
+	assert.Equal(t, "com.example.app", (<-received).Header.Get("apns-topic"))
+}
+
+func TestQueueResumesAfterRestart(t *testing.T) {
+	directory, _ := ioutil.TempDir("", "goapns")
+	defer os.RemoveAll(directory)
+	path := filepath.Join(directory, "queue.log")
+
+	//The first process stored the jobs but stopped before they were delivered.
+	store, err := goapns.NewFileQueueStore(path)
+	assert.Nil(t, err)
+	message := mockMessage().Topic("com.example.app").CollapseID("score")
+	envelope, _ := message.Envelope()
+	tokens := testTokens(3)
+	for i, token := range tokens {
+		assert.Nil(t, store.Append(goapns.QueueJob{ID: fmt.Sprint(i), Envelope: envelope, Token: token}))
+	}
+	assert.Nil(t, store.Ack("0"))
+	assert.Nil(t, store.Close())
+
+	conn := mockConnection(t)
+	received := make(chan *http.Request, len(tokens))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r
+	}))
+	defer server.Close()
+	conn.Host = server.URL
+
+	store, err = goapns.NewFileQueueStore(path)
+	assert.Nil(t, err)
+	defer store.Close()
+	queue, err := goapns.NewQueue(conn, store, 1)
+	assert.Nil(t, err)
+
+	for _, token := range tokens[1:] {
+		response := <-queue.Responses()
+		assert.True(t, response.Sent())
+		assert.Equal(t, token, response.Token)
+
+		request := <-received
+		assert.Equal(t, "/3/device/"+token, request.URL.Path)
+		assert.Equal(t, "com.example.app", request.Header.Get("apns-topic"))
+		assert.Equal(t, "score", request.Header.Get("apns-collapse-id"))
+	}
+	queue.Close()
+
+	pending, _ := store.Pending()
+	assert.Empty(t, pending)
+}
+
+func TestFileQueueStoreIncompleteRecord(t *testing.T) {
+	directory, _ := ioutil.TempDir("", "goapns")
+	defer os.RemoveAll(directory)
+	path := filepath.Join(directory, "queue.log")
+
+	store, err := goapns.NewFileQueueStore(path)
+	assert.Nil(t, err)
+	assert.Nil(t, store.Append(goapns.QueueJob{ID: "1", Token: "a"}, goapns.QueueJob{ID: "2", Token: "b"}))
+	assert.Nil(t, store.Ack("1"))
+	assert.Nil(t, store.Close())
+
+	//The process stopped while it wrote the next record.
+	file, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	file.WriteString(`{"job":{"id":"3","tok`)
+	file.Close()
+
+	store, err = goapns.NewFileQueueStore(path)
+	assert.Nil(t, err)
+	pending, _ := store.Pending()
+	assert.Len(t, pending, 1)
+	assert.Equal(t, "2", pending[0].ID)
+	assert.Nil(t, store.Close())
+
+	//Opening compacted the log to the pending job.
+	data, _ := ioutil.ReadFile(path)
+	assert.Equal(t, 1, strings.Count(string(data), "\n"))
+
+	ioutil.WriteFile(path, []byte("garbage\n{\"ack\":\"2\"}\n"), 0600)
+	_, err = goapns.NewFileQueueStore(path)
+	assert.Equal(t, goapns.ErrorQueueCorrupted, err)
+}
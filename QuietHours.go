@@ -0,0 +1,162 @@
+package goapns
+
+import (
+	"errors"
+	"time"
+)
+
+//Quiet hours errors.
+var (
+	ErrorDeferred          = errors.New("The notification was deferred because the user has quiet hours. Response.DeferredUntil tells you when they end.")
+	ErrorQuietHoursExpired = errors.New("The notification was dropped because it would expire before the quiet hours of the user end.")
+)
+
+//QuietHoursAction is what a QuietHoursPolicy does with a notification that would expire
+//before the quiet hours of the user end.
+type QuietHoursAction int
+
+const (
+	//QuietHoursDowngrade sends the notification right away with PriorityLow,
+	//so Apple delivers it when the device is awake anyway.
+	QuietHoursDowngrade QuietHoursAction = iota
+	//QuietHoursDrop does not send the notification, you get ErrorQuietHoursExpired.
+	QuietHoursDrop
+)
+
+//QuietHours is the time of day during which a user does not want to receive notifications.
+type QuietHours struct {
+	//Location is the time zone of the user, UTC if it is nil.
+	Location *time.Location
+	//Start is the time of day at which the quiet hours begin, as time since midnight.
+	Start time.Duration
+	//End is the time of day at which the quiet hours end, as time since midnight.
+	//If it is before Start, the quiet hours last over midnight, for example from 22:00 to 7:00.
+	//If it equals Start, there are no quiet hours.
+	End time.Duration
+}
+
+//NewQuietHours creates the QuietHours from the hour and minute of start to the hour and minute of end
+//in the time zone of the user, for example NewQuietHours(location, 22, 0, 7, 30).
+func NewQuietHours(location *time.Location, startHour int, startMinute int, endHour int, endMinute int) QuietHours {
+	return QuietHours{
+		Location: location,
+		Start:    time.Duration(startHour)*time.Hour + time.Duration(startMinute)*time.Minute,
+		End:      time.Duration(endHour)*time.Hour + time.Duration(endMinute)*time.Minute,
+	}
+}
+
+//Contains returns true if t is within the quiet hours.
+func (q QuietHours) Contains(t time.Time) bool {
+	return !q.Until(t).IsZero()
+}
+
+//Until returns the time at which the quiet hours end if t is within them, the zero time otherwise.
+//The times of day are wall clock times in the Location of the user, so they stay the same
+//when daylight saving time begins or ends.
+func (q QuietHours) Until(t time.Time) time.Time {
+	if q.Start == q.End {
+		return time.Time{}
+	}
+	location := q.Location
+	if location == nil {
+		location = time.UTC
+	}
+	t = t.In(location)
+
+	//The quiet hours that began today, and the ones that began yesterday and may last until today.
+	for _, days := range []int{0, -1} {
+		start := q.timeOfDay(t, days, q.Start)
+		end := q.timeOfDay(t, days, q.End)
+		if q.End < q.Start {
+			end = q.timeOfDay(t, days+1, q.End)
+		}
+		if !t.Before(start) && t.Before(end) {
+			return end
+		}
+	}
+	return time.Time{}
+}
+
+//timeOfDay returns the wall clock time of day on the day of t, moved by days.
+func (q QuietHours) timeOfDay(t time.Time, days int, since time.Duration) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day()+days, 0, 0, int(since/time.Second), 0, t.Location())
+}
+
+//QuietHoursPolicy defers notifications that would arrive during the quiet hours of a user.
+//Set it as QuietHours of a Connection to enable it.
+//
+//A notification to a token with quiet hours is not sent, you get ErrorDeferred and
+//Response.DeferredUntil instead. If the Policy has a Scheduler, the notification is scheduled
+//for the end of the quiet hours. If the Expiration of the Message is before the quiet hours end,
+//the notification is handled as the Expired action says.
+type QuietHoursPolicy struct {
+	//QuietHours returns the quiet hours of the user the token belongs to and false if there are none.
+	QuietHours func(token string) (QuietHours, bool)
+
+	//Expired is what happens to a notification whose Expiration is before the quiet hours end.
+	//The default is QuietHoursDowngrade.
+	Expired QuietHoursAction
+
+	//Scheduler sends deferred notifications at the end of the quiet hours. If it is nil,
+	//it is up to you to send them again. Use a Scheduler that sends through the same
+	//Connection and Clock.
+	Scheduler *Scheduler
+
+	//Clock tells the current time. It is nil by default, which means the system time.
+	Clock Clock
+}
+
+//quietHoursDecision is what the QuietHoursPolicy decided for a notification.
+type quietHoursDecision int
+
+const (
+	quietHoursSend quietHoursDecision = iota
+	quietHoursDowngrade
+	quietHoursDefer
+	quietHoursDrop
+)
+
+//decide returns what happens to the Message to the token and the end of the quiet hours.
+func (p *QuietHoursPolicy) decide(message *Message, token string) (quietHoursDecision, time.Time) {
+	if p == nil || p.QuietHours == nil {
+		return quietHoursSend, time.Time{}
+	}
+	quietHours, found := p.QuietHours(token)
+	if !found {
+		return quietHoursSend, time.Time{}
+	}
+
+	clock := p.Clock
+	if clock == nil {
+		clock = systemClock{}
+	}
+	until := quietHours.Until(clock.Now())
+	if until.IsZero() {
+		return quietHoursSend, time.Time{}
+	}
+
+	expiration := message.Header.Expiration
+	if expiration.IsZero() || !expiration.Before(until) {
+		return quietHoursDefer, until
+	}
+	if p.Expired == QuietHoursDrop {
+		return quietHoursDrop, until
+	}
+	return quietHoursDowngrade, until
+}
+
+//downgrade returns a copy of the Message with PriorityLow. Messages that have a lower priority
+//already, or whose push type requires PriorityHigh, are returned as they are.
+//A Priority of 0 is not set, Apple assumes PriorityHigh then.
+func downgrade(message *Message) *Message {
+	if message.Header.Priority != PriorityHigh && message.Header.Priority != 0 {
+		return message
+	}
+	//A shallow copy is enough, only the Header is changed.
+	downgraded := *message
+	downgraded.Header.Priority = PriorityLow
+	if downgraded.Header.validate(message.InferredPushType()) != nil {
+		return message
+	}
+	return &downgraded
+}
@@ -0,0 +1,131 @@
+package goapns_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tantalum73/Go-APNS"
+)
+
+func TestQuietHoursUntil(t *testing.T) {
+	night := goapns.NewQuietHours(time.UTC, 22, 0, 7, 30)
+	day := goapns.NewQuietHours(time.UTC, 12, 0, 14, 0)
+
+	tests := []struct {
+		quietHours goapns.QuietHours
+		at         time.Time
+		until      time.Time
+	}{
+		{night, time.Date(2024, time.January, 10, 21, 59, 0, 0, time.UTC), time.Time{}},
+		{night, time.Date(2024, time.January, 10, 22, 0, 0, 0, time.UTC), time.Date(2024, time.January, 11, 7, 30, 0, 0, time.UTC)},
+		{night, time.Date(2024, time.January, 11, 3, 0, 0, 0, time.UTC), time.Date(2024, time.January, 11, 7, 30, 0, 0, time.UTC)},
+		{night, time.Date(2024, time.January, 11, 7, 30, 0, 0, time.UTC), time.Time{}},
+		{day, time.Date(2024, time.January, 10, 13, 0, 0, 0, time.UTC), time.Date(2024, time.January, 10, 14, 0, 0, 0, time.UTC)},
+		{day, time.Date(2024, time.January, 10, 3, 0, 0, 0, time.UTC), time.Time{}},
+		{goapns.QuietHours{}, time.Date(2024, time.January, 10, 3, 0, 0, 0, time.UTC), time.Time{}},
+	}
+	for _, test := range tests {
+		assert.True(t, test.until.Equal(test.quietHours.Until(test.at)), "%v", test.at)
+		assert.Equal(t, !test.until.IsZero(), test.quietHours.Contains(test.at), "%v", test.at)
+	}
+}
+
+func TestQuietHoursTimeZone(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skip("time zone database is not available")
+	}
+	quietHours := goapns.NewQuietHours(tokyo, 22, 0, 7, 0)
+
+	//18:00 UTC is 3:00 in Tokyo.
+	until := quietHours.Until(time.Date(2024, time.January, 10, 18, 0, 0, 0, time.UTC))
+	assert.True(t, time.Date(2024, time.January, 10, 22, 0, 0, 0, time.UTC).Equal(until))
+	assert.False(t, quietHours.Contains(time.Date(2024, time.January, 10, 9, 0, 0, 0, time.UTC)))
+}
+
+//quietHoursPolicy gives the token 1234567890 quiet hours from 22:00 to 7:00 UTC.
+func quietHoursPolicy(clock goapns.Clock) *goapns.QuietHoursPolicy {
+	return &goapns.QuietHoursPolicy{
+		QuietHours: func(token string) (goapns.QuietHours, bool) {
+			return goapns.NewQuietHours(time.UTC, 22, 0, 7, 0), token == testToken
+		},
+		Clock: clock,
+	}
+}
+
+func TestQuietHoursDefer(t *testing.T) {
+	clock := newFakeClock(time.Date(2024, time.January, 10, 23, 0, 0, 0, time.UTC))
+	conn, received, stop := recordingConnection(t)
+	defer stop()
+	conn.QuietHours = quietHoursPolicy(clock)
+
+	response, err := conn.Send(context.Background(), mockMessage(), testToken)
+	assert.Equal(t, goapns.ErrorDeferred, err)
+	assert.False(t, response.Sent())
+	assert.True(t, time.Date(2024, time.January, 11, 7, 0, 0, 0, time.UTC).Equal(response.DeferredUntil))
+	assert.Empty(t, response.ScheduleID)
+	assert.Empty(t, received)
+
+	//Tokens without quiet hours are sent right away.
+	response, err = conn.Send(context.Background(), mockMessage(), otherTestToken)
+	assert.Nil(t, err)
+	assert.True(t, response.Sent())
+	<-received
+}
+
+func TestQuietHoursScheduler(t *testing.T) {
+	clock := newFakeClock(time.Date(2024, time.January, 10, 23, 0, 0, 0, time.UTC))
+	conn, received, stop := recordingConnection(t)
+	defer stop()
+	conn.QuietHours = quietHoursPolicy(clock)
+
+	scheduler, err := goapns.NewScheduler(conn, goapns.NewMemoryScheduleStore(), clock)
+	assert.Nil(t, err)
+	defer scheduler.Close()
+	conn.QuietHours.Scheduler = scheduler
+
+	response, err := conn.Send(context.Background(), mockMessage(), testToken)
+	assert.Equal(t, goapns.ErrorDeferred, err)
+	assert.NotEmpty(t, response.ScheduleID)
+	assert.Equal(t, response.ScheduleID, scheduler.Schedules()[0].ID)
+	assert.True(t, response.DeferredUntil.Equal(scheduler.Schedules()[0].At))
+
+	clock.Advance(8 * time.Hour)
+	response = <-scheduler.Responses()
+	assert.True(t, response.Sent())
+	assert.Equal(t, "/3/device/"+testToken, (<-received).URL.Path)
+}
+
+func TestQuietHoursExpiration(t *testing.T) {
+	now := time.Date(2024, time.January, 10, 23, 0, 0, 0, time.UTC)
+	conn, received, stop := recordingConnection(t)
+	defer stop()
+	conn.QuietHours = quietHoursPolicy(newFakeClock(now))
+
+	message := mockMessage().Expiration(now.Add(time.Hour))
+	response, err := conn.Send(context.Background(), message, testToken)
+	assert.Nil(t, err)
+	assert.True(t, response.Sent())
+	assert.Equal(t, "5", (<-received).Header.Get("apns-priority"))
+	assert.Equal(t, goapns.PriorityHigh, message.Header.Priority)
+
+	//A Priority that is not set is high and downgraded as well.
+	unset := mockMessage().Expiration(now.Add(time.Hour))
+	unset.Header.Priority = 0
+	response, err = conn.Send(context.Background(), unset, testToken)
+	assert.Nil(t, err)
+	assert.True(t, response.Sent())
+	assert.Equal(t, "5", (<-received).Header.Get("apns-priority"))
+	assert.Equal(t, 0, unset.Header.Priority)
+
+	conn.QuietHours.Expired = goapns.QuietHoursDrop
+	_, err = conn.Send(context.Background(), message, testToken)
+	assert.Equal(t, goapns.ErrorQuietHoursExpired, err)
+	assert.Empty(t, received)
+
+	//An Expiration after the quiet hours defers the notification.
+	_, err = conn.Send(context.Background(), mockMessage().Expiration(now.Add(24*time.Hour)), testToken)
+	assert.Equal(t, goapns.ErrorDeferred, err)
+}
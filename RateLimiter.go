@@ -0,0 +1,259 @@
+package goapns
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+//ErrorRateLimited is returned if a notification was not sent because it exceeded a limit of the RateLimiter.
+var ErrorRateLimited = errors.New("The notification was not sent because it exceeded a rate limit of the connection.")
+
+//RateLimitScope names one of the limits of a RateLimiter.
+type RateLimitScope string
+
+//The scopes of the limits of a RateLimiter.
+const (
+	RateLimitGlobal RateLimitScope = "global"
+	RateLimitTopic  RateLimitScope = "topic"
+	RateLimitToken  RateLimitScope = "token"
+)
+
+//RateLimitMode is what a RateLimiter does with a notification that exceeds a limit.
+type RateLimitMode int
+
+const (
+	//RateLimitDelay waits until the notification is within the limits again.
+	RateLimitDelay RateLimitMode = iota
+	//RateLimitReject does not send the notification, you get ErrorRateLimited.
+	RateLimitReject
+)
+
+//rateLimitSweepInterval is the number of new buckets after which full buckets are removed.
+const rateLimitSweepInterval = 1024
+
+//RateLimit allows Count notifications per Period with bursts of up to Burst notifications.
+//A RateLimit with a Count of 0 does not limit anything.
+type RateLimit struct {
+	//Count is the number of notifications that are allowed per Period.
+	Count int
+	//Period is the time in which Count notifications are allowed.
+	Period time.Duration
+	//Burst is the number of notifications that can be sent at once. Count is used if it is 0.
+	Burst int
+}
+
+//PerSecond creates a RateLimit of count notifications per second.
+func PerSecond(count int) RateLimit {
+	return RateLimit{Count: count, Period: time.Second}
+}
+
+//PerMinute creates a RateLimit of count notifications per minute.
+func PerMinute(count int) RateLimit {
+	return RateLimit{Count: count, Period: time.Minute}
+}
+
+//PerHour creates a RateLimit of count notifications per hour.
+func PerHour(count int) RateLimit {
+	return RateLimit{Count: count, Period: time.Hour}
+}
+
+//enabled reports if the RateLimit limits anything.
+func (l RateLimit) enabled() bool {
+	return l.Count > 0 && l.Period > 0
+}
+
+//burst returns the capacity of the bucket.
+func (l RateLimit) burst() float64 {
+	if l.Burst > 0 {
+		return float64(l.Burst)
+	}
+	return float64(l.Count)
+}
+
+//RateLimitDecision describes what the RateLimiter of a Connection did with a notification.
+type RateLimitDecision struct {
+	//Scope is the limit that delayed or rejected the notification, empty if it was sent right away.
+	Scope RateLimitScope
+	//Delayed is the time the notification waited for the limit.
+	Delayed time.Duration
+	//Rejected is true if the notification was not sent because of the limit.
+	Rejected bool
+}
+
+//RateLimiter limits how often notifications are sent, in total, per topic and per device token,
+//before they reach Apples servers. Apple answers with ErrorTooManyRequests if you push to one
+//device token too often. Set it as RateLimiter of a Connection to enable it.
+//
+//Every limit is a token bucket that allows bursts and refills at the rate of its RateLimit.
+//A notification must fit into every limit. If it does not, it waits or is rejected, as Mode says.
+//Retries of a RetryPolicy are not limited, they wait for their backoff.
+//Set the limits before the RateLimiter is used, it is safe for concurrent use then.
+type RateLimiter struct {
+	//Global limits every notification of the Connection.
+	Global RateLimit
+	//PerTopic limits the notifications to each Header.Topic.
+	PerTopic RateLimit
+	//PerToken limits the notifications to each device token.
+	PerToken RateLimit
+
+	//Mode is what happens to a notification that exceeds a limit. The default is RateLimitDelay.
+	Mode RateLimitMode
+	//MaxDelay is the longest time a notification waits in RateLimitDelay mode, it is rejected
+	//if it would have to wait longer. 0 means that it waits as long as the context allows.
+	MaxDelay time.Duration
+
+	//Clock tells the current time and waits. It is nil by default, which means the system time.
+	Clock Clock
+
+	mutex  sync.Mutex
+	global rateBucket
+	topics map[string]*rateBucket
+	tokens map[string]*rateBucket
+	added  int
+}
+
+//NewRateLimiter creates a RateLimiter in RateLimitDelay mode that allows at most perToken
+//notifications to each device token. Set the other limits as you need them.
+func NewRateLimiter(perToken RateLimit) *RateLimiter {
+	return &RateLimiter{PerToken: perToken}
+}
+
+//rateBucket is the state of the token bucket of a RateLimit.
+type rateBucket struct {
+	available float64
+	last      time.Time
+}
+
+//refill adds what the RateLimit allows since the last time and returns the bucket.
+func (b *rateBucket) refill(limit RateLimit, now time.Time) *rateBucket {
+	if b.last.IsZero() {
+		b.available = limit.burst()
+	} else if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.available = math.Min(limit.burst(), b.available+float64(limit.Count)*float64(elapsed)/float64(limit.Period))
+	}
+	b.last = now
+	return b
+}
+
+//delay returns the time until the bucket has room for one notification.
+func (b *rateBucket) delay(limit RateLimit) time.Duration {
+	if b.available >= 1 {
+		return 0
+	}
+	return time.Duration(math.Ceil((1 - b.available) * float64(limit.Period) / float64(limit.Count)))
+}
+
+//reservation is one notification taken from the buckets it was limited by.
+type reservation struct {
+	limits  []RateLimit
+	buckets []*rateBucket
+}
+
+//wait takes the notification from every limit and waits until it may be sent.
+//It returns the decision and ErrorRateLimited or the error of the context if it must not be sent.
+func (r *RateLimiter) wait(ctx context.Context, message *Message, token string) (RateLimitDecision, error) {
+	if r == nil {
+		return RateLimitDecision{}, nil
+	}
+	clock := r.Clock
+	if clock == nil {
+		clock = systemClock{}
+	}
+
+	decision, taken, err := r.reserve(message.Header.Topic, token, clock.Now())
+	if err != nil || decision.Delayed == 0 {
+		return decision, err
+	}
+
+	select {
+	case <-clock.After(decision.Delayed):
+		return decision, nil
+	case <-ctx.Done():
+		//The notification is not sent, others may use its place.
+		r.cancel(taken)
+		return decision, ctx.Err()
+	}
+}
+
+//reserve takes the notification from the buckets of every limit and returns how long it has to wait.
+//Nothing is taken if it is rejected.
+func (r *RateLimiter) reserve(topic string, token string, now time.Time) (RateLimitDecision, reservation, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.added >= rateLimitSweepInterval {
+		r.sweep(r.topics, r.PerTopic, now)
+		r.sweep(r.tokens, r.PerToken, now)
+		r.added = 0
+	}
+
+	var taken reservation
+	var decision RateLimitDecision
+	add := func(scope RateLimitScope, limit RateLimit, bucket *rateBucket) {
+		bucket.refill(limit, now)
+		if delay := bucket.delay(limit); delay > decision.Delayed {
+			decision.Delayed = delay
+			decision.Scope = scope
+		}
+		taken.limits = append(taken.limits, limit)
+		taken.buckets = append(taken.buckets, bucket)
+	}
+
+	if r.Global.enabled() {
+		add(RateLimitGlobal, r.Global, &r.global)
+	}
+	if r.PerTopic.enabled() {
+		add(RateLimitTopic, r.PerTopic, r.bucket(&r.topics, topic))
+	}
+	if r.PerToken.enabled() {
+		add(RateLimitToken, r.PerToken, r.bucket(&r.tokens, token))
+	}
+
+	if decision.Delayed > 0 && (r.Mode == RateLimitReject || (r.MaxDelay > 0 && decision.Delayed > r.MaxDelay)) {
+		return RateLimitDecision{Scope: decision.Scope, Rejected: true}, reservation{}, ErrorRateLimited
+	}
+	for _, bucket := range taken.buckets {
+		bucket.available--
+	}
+	return decision, taken, nil
+}
+
+//cancel gives the notification back to the buckets it was taken from.
+func (r *RateLimiter) cancel(taken reservation) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for i, bucket := range taken.buckets {
+		bucket.available = math.Min(taken.limits[i].burst(), bucket.available+1)
+	}
+}
+
+//bucket returns the bucket of the key and creates it if it does not exist.
+//The caller must hold the mutex.
+func (r *RateLimiter) bucket(buckets *map[string]*rateBucket, key string) *rateBucket {
+	if *buckets == nil {
+		*buckets = make(map[string]*rateBucket)
+	}
+	if bucket, found := (*buckets)[key]; found {
+		return bucket
+	}
+
+	r.added++
+	bucket := &rateBucket{}
+	(*buckets)[key] = bucket
+	return bucket
+}
+
+//sweep removes the buckets that are full, a full bucket behaves the same as one that does not exist.
+//It runs every rateLimitSweepInterval new buckets, so that the buckets of tokens that are not
+//used anymore do not pile up. The caller must hold the mutex.
+func (r *RateLimiter) sweep(buckets map[string]*rateBucket, limit RateLimit, now time.Time) {
+	for key, bucket := range buckets {
+		if bucket.refill(limit, now).available >= limit.burst() {
+			delete(buckets, key)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package goapns_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tantalum73/Go-APNS"
+)
+
+func TestRateLimiterReject(t *testing.T) {
+	clock := newFakeClock(time.Date(2024, time.January, 10, 8, 0, 0, 0, time.UTC))
+	limiter := goapns.NewRateLimiter(goapns.RateLimit{Count: 2, Period: time.Minute})
+	limiter.Mode = goapns.RateLimitReject
+	limiter.Clock = clock
+	conn, _, stop := recordingConnection(t)
+	defer stop()
+	conn.RateLimiter = limiter
+
+	for i := 0; i < 2; i++ {
+		response, err := conn.Send(context.Background(), mockMessage(), testToken)
+		assert.Nil(t, err)
+		assert.Equal(t, goapns.RateLimitDecision{}, response.RateLimit)
+	}
+
+	response, err := conn.Send(context.Background(), mockMessage(), testToken)
+	assert.Equal(t, goapns.ErrorRateLimited, err)
+	assert.False(t, response.Sent())
+	assert.Equal(t, goapns.RateLimitDecision{Scope: goapns.RateLimitToken, Rejected: true}, response.RateLimit)
+
+	//Other tokens have their own limit.
+	_, err = conn.Send(context.Background(), mockMessage(), otherTestToken)
+	assert.Nil(t, err)
+
+	//The bucket refills with one notification every 30 seconds.
+	clock.Advance(30 * time.Second)
+	_, err = conn.Send(context.Background(), mockMessage(), testToken)
+	assert.Nil(t, err)
+	_, err = conn.Send(context.Background(), mockMessage(), testToken)
+	assert.Equal(t, goapns.ErrorRateLimited, err)
+}
+
+func TestRateLimiterTopicAndGlobal(t *testing.T) {
+	clock := newFakeClock(time.Date(2024, time.January, 10, 8, 0, 0, 0, time.UTC))
+	limiter := &goapns.RateLimiter{
+		Global:   goapns.RateLimit{Count: 10, Period: time.Second, Burst: 3},
+		PerTopic: goapns.PerSecond(1),
+		Mode:     goapns.RateLimitReject,
+		Clock:    clock,
+	}
+	conn, _, stop := recordingConnection(t)
+	defer stop()
+	conn.RateLimiter = limiter
+
+	_, err := conn.Send(context.Background(), mockMessage().Topic("com.example.a"), testToken)
+	assert.Nil(t, err)
+	response, err := conn.Send(context.Background(), mockMessage().Topic("com.example.a"), testToken)
+	assert.Equal(t, goapns.ErrorRateLimited, err)
+	assert.Equal(t, goapns.RateLimitTopic, response.RateLimit.Scope)
+
+	_, err = conn.Send(context.Background(), mockMessage().Topic("com.example.b"), testToken)
+	assert.Nil(t, err)
+	_, err = conn.Send(context.Background(), mockMessage().Topic("com.example.c"), testToken)
+	assert.Nil(t, err)
+	response, err = conn.Send(context.Background(), mockMessage().Topic("com.example.d"), testToken)
+	assert.Equal(t, goapns.ErrorRateLimited, err)
+	assert.Equal(t, goapns.RateLimitGlobal, response.RateLimit.Scope)
+}
+
+func TestRateLimiterDelay(t *testing.T) {
+	clock := newFakeClock(time.Date(2024, time.January, 10, 8, 0, 0, 0, time.UTC))
+	limiter := goapns.NewRateLimiter(goapns.PerMinute(1))
+	limiter.Clock = clock
+	conn, _, stop := recordingConnection(t)
+	defer stop()
+	conn.RateLimiter = limiter
+
+	_, err := conn.Send(context.Background(), mockMessage(), testToken)
+	assert.Nil(t, err)
+
+	done := make(chan goapns.Response)
+	go func() {
+		response, _ := conn.Send(context.Background(), mockMessage(), testToken)
+		done <- response
+	}()
+	for clock.waiting() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case <-done:
+		t.Fatal("the notification was sent before the limit allowed it")
+	default:
+	}
+
+	clock.Advance(time.Minute)
+	response := <-done
+	assert.True(t, response.Sent())
+	assert.Equal(t, goapns.RateLimitDecision{Scope: goapns.RateLimitToken, Delayed: time.Minute}, response.RateLimit)
+
+	//A delay that exceeds MaxDelay is rejected.
+	limiter.MaxDelay = 30 * time.Second
+	response, err = conn.Send(context.Background(), mockMessage(), testToken)
+	assert.Equal(t, goapns.ErrorRateLimited, err)
+	assert.True(t, response.RateLimit.Rejected)
+}
+
+func TestRateLimiterContext(t *testing.T) {
+	clock := newFakeClock(time.Date(2024, time.January, 10, 8, 0, 0, 0, time.UTC))
+	limiter := goapns.NewRateLimiter(goapns.PerMinute(1))
+	limiter.Clock = clock
+	conn, _, stop := recordingConnection(t)
+	defer stop()
+	conn.RateLimiter = limiter
+
+	_, err := conn.Send(context.Background(), mockMessage(), testToken)
+	assert.Nil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		for clock.waiting() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		cancel()
+	}()
+	response, err := conn.Send(ctx, mockMessage(), testToken)
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, goapns.RateLimitToken, response.RateLimit.Scope)
+
+	//The cancelled notification gave its place back.
+	limiter.Mode = goapns.RateLimitReject
+	clock.Advance(time.Minute)
+	_, err = conn.Send(context.Background(), mockMessage(), testToken)
+	assert.Nil(t, err)
+}
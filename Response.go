@@ -32,6 +32,13 @@ var (
 	ErrorServiceUnavailable        = errors.New("The service is unavailable.")
 	ErrorMissingTopic              = errors.New("The apns-topic header of the request was not specified and was required. The apns-topic header is mandatory when the client is connected using a certificate that supports multiple topics.")
 
+	// Provider token errors, only returned to token-based connections.
+
+	ErrorExpiredProviderToken        = errors.New("The provider token is stale and a new token should be generated.")
+	ErrorInvalidProviderToken        = errors.New("The provider token is not valid or the token signature could not be verified.")
+	ErrorMissingProviderToken        = errors.New("No provider certificate was used to connect to APNs and the authorization header was missing or no provider token was specified.")
+	ErrorTooManyProviderTokenUpdates = errors.New("The provider token is being updated too often.")
+
 	// HTTP Status errors.
 
 	ErrorBadRequest = errors.New("Bad request.")
@@ -64,6 +71,11 @@ var errorReason = map[string]error{
 	"InternalServerError":       ErrorInternalServerError,
 	"ServiceUnavailable":        ErrorServiceUnavailable,
 	"MissingTopic":              ErrorMissingTopic,
+
+	"ExpiredProviderToken":        ErrorExpiredProviderToken,
+	"InvalidProviderToken":        ErrorInvalidProviderToken,
+	"MissingProviderToken":        ErrorMissingProviderToken,
+	"TooManyProviderTokenUpdates": ErrorTooManyProviderTokenUpdates,
 }
 
 var errorStatus = map[int]error{
@@ -105,6 +117,45 @@ type Response struct {
 
 	//Message object that failed to sent.
 	Message *Message
+
+	//APNSID identifies the notification. It is the apns-id that Apple returned,
+	//which is the APNSID of the Header or a new UUID if you did not set one.
+	APNSID string
+
+	//Truncated is the number of bytes that were cut from the alert body to fit the maximum payload size.
+	//It is only set if truncation was enabled with Message.TruncateBody.
+	Truncated int
+
+	//Attempts is the number of requests that were made to deliver the notification.
+	//It is greater than 1 if the Connection has a RetryPolicy that sent it again.
+	Attempts int
+
+	//DeferredUntil is the end of the quiet hours of the user if the notification was deferred
+	//by the QuietHoursPolicy of the Connection, Error is ErrorDeferred in this case.
+	DeferredUntil time.Time
+
+	//ScheduleID is the ID of the Schedule that sends a deferred notification,
+	//if the QuietHoursPolicy has a Scheduler.
+	ScheduleID string
+
+	//RateLimit tells you if the RateLimiter of the Connection delayed or rejected the notification.
+	//Error is ErrorRateLimited if it was rejected.
+	RateLimit RateLimitDecision
+
+	//networkError is true if the request failed before Apples servers responded.
+	networkError bool
+}
+
+//newErrorResponse creates a Response for a notification that could not be sent to the token.
+func newErrorResponse(message *Message, token string, err error) Response {
+	response := Response{}
+	response.Error = err
+	response.Message = message
+	response.Token = token
+	if message != nil {
+		response.APNSID = message.Header.APNSID
+	}
+	return response
 }
 
 //Sent return true if the notification was sent successfully (http status code == 200).
@@ -113,6 +164,12 @@ func (r *Response) Sent() bool {
 	return r.StatusCode == http.StatusOK
 }
 
+//NetworkError returns true if the request failed before Apples servers responded,
+//for example because the connection was lost. Those errors are worth a retry.
+func (r *Response) NetworkError() bool {
+	return r.networkError
+}
+
 //Timestamp converts the int64 type from a Response into a time.Time object.
 func (r *Response) Timestamp() time.Time {
 	// if r.TimestempNumber != 0 {
@@ -0,0 +1,98 @@
+package goapns
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+//RetryPolicy specifies if and how often a notification is sent again if it failed
+//with an error that is likely to go away, for example ErrorTooManyRequests,
+//ErrorServiceUnavailable or a lost connection.
+//Set it as RetryPolicy of a Connection to enable retries. The Response you get
+//is the one of the last attempt, Response.Attempts tells you how many requests were made.
+type RetryPolicy struct {
+	//MaxAttempts is the maximum number of requests that are made for one notification,
+	//including the first one. A value <= 1 disables retries.
+	MaxAttempts int
+
+	//BaseBackoff is the time to wait before the first retry.
+	//It doubles with every further attempt.
+	BaseBackoff time.Duration
+
+	//MaxBackoff is the upper limit of the time to wait between two attempts.
+	MaxBackoff time.Duration
+
+	//Jitter randomizes the backoff by the given fraction (0.0 - 1.0) so that
+	//many failed notifications are not sent again at the very same time.
+	//A Jitter of 0.2 means that the backoff varies between 80% and 120%.
+	Jitter float64
+
+	//Retryable decides if a failed Response should be sent again.
+	//DefaultRetryable is used if it is nil.
+	Retryable func(response Response) bool
+}
+
+//NewRetryPolicy creates a RetryPolicy with three attempts, a backoff between
+//100 milliseconds and 5 seconds, 20% jitter and DefaultRetryable as classification.
+func NewRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: 3,
+		BaseBackoff: 100 * time.Millisecond,
+		MaxBackoff:  5 * time.Second,
+		Jitter:      0.2,
+		Retryable:   DefaultRetryable,
+	}
+}
+
+//DefaultRetryable returns true for errors that are worth another attempt:
+//ErrorTooManyRequests, ErrorInternalServerError, ErrorServiceUnavailable,
+//ErrorShutdown, ErrorIdleTimeout and network errors.
+//Every other error is caused by the notification itself and will not go away by retrying.
+func DefaultRetryable(response Response) bool {
+	switch response.Error {
+	case ErrorTooManyRequests, ErrorInternalServerError, ErrorServiceUnavailable, ErrorShutdown, ErrorIdleTimeout:
+		return true
+	}
+	return response.NetworkError()
+}
+
+//retryable reports if the Response should be sent again after the given number of attempts.
+func (p *RetryPolicy) retryable(response Response, attempts int) bool {
+	if p == nil || response.Error == nil || attempts >= p.MaxAttempts {
+		return false
+	}
+	if p.Retryable == nil {
+		return DefaultRetryable(response)
+	}
+	return p.Retryable(response)
+}
+
+//backoff returns the time to wait after the given number of attempts.
+func (p *RetryPolicy) backoff(attempts int) time.Duration {
+	backoff := p.BaseBackoff
+	for i := 1; i < attempts && (p.MaxBackoff <= 0 || backoff < p.MaxBackoff); i++ {
+		backoff *= 2
+	}
+	if p.Jitter > 0 {
+		backoff = time.Duration(float64(backoff) * (1 - p.Jitter + 2*p.Jitter*rand.Float64()))
+	}
+	if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+	return backoff
+}
+
+//wait blocks for the backoff after the given number of attempts.
+//It returns false if the context is done before.
+func (p *RetryPolicy) wait(ctx context.Context, attempts int) bool {
+	timer := time.NewTimer(p.backoff(attempts))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
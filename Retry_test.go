@@ -0,0 +1,104 @@
+package goapns_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tantalum73/Go-APNS"
+)
+
+func mockRetryPolicy() *goapns.RetryPolicy {
+	policy := goapns.NewRetryPolicy()
+	policy.BaseBackoff = time.Millisecond
+	policy.MaxBackoff = 5 * time.Millisecond
+	return policy
+}
+
+func TestRetryUntilSent(t *testing.T) {
+	conn := mockConnection(t)
+	conn.RetryPolicy = mockRetryPolicy()
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"reason": "ServiceUnavailable"}`))
+		}
+	}))
+	defer server.Close()
+
+	conn.Host = server.URL
+	response, err := conn.Send(context.Background(), mockMessage(), testToken)
+	assert.Nil(t, err)
+	assert.True(t, response.Sent())
+	assert.Equal(t, 3, response.Attempts)
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	conn := mockConnection(t)
+	conn.RetryPolicy = mockRetryPolicy()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"reason": "TooManyRequests"}`))
+	}))
+	defer server.Close()
+
+	conn.Host = server.URL
+	response, err := conn.Send(context.Background(), mockMessage(), testToken)
+	assert.Equal(t, goapns.ErrorTooManyRequests, err)
+	assert.Equal(t, conn.RetryPolicy.MaxAttempts, response.Attempts)
+}
+
+func TestRetryNotRetryable(t *testing.T) {
+	conn := mockConnection(t)
+	conn.RetryPolicy = mockRetryPolicy()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"reason": "BadDeviceToken"}`))
+	}))
+	defer server.Close()
+
+	conn.Host = server.URL
+	response, err := conn.Send(context.Background(), mockMessage(), testToken)
+	assert.Equal(t, goapns.ErrorBadDeviceToken, err)
+	assert.Equal(t, 1, response.Attempts)
+}
+
+func TestRetryNetworkError(t *testing.T) {
+	conn := mockConnection(t)
+	conn.RetryPolicy = mockRetryPolicy()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	conn.Host = server.URL
+	server.Close()
+
+	response, err := conn.Send(context.Background(), mockMessage(), testToken)
+	assert.Error(t, err)
+	assert.True(t, response.NetworkError())
+	assert.Equal(t, conn.RetryPolicy.MaxAttempts, response.Attempts)
+}
+
+func TestRetryCustomClassification(t *testing.T) {
+	conn := mockConnection(t)
+	conn.RetryPolicy = mockRetryPolicy()
+	conn.RetryPolicy.Retryable = func(response goapns.Response) bool {
+		return response.Error == goapns.ErrorBadDeviceToken
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"reason": "BadDeviceToken"}`))
+	}))
+	defer server.Close()
+
+	conn.Host = server.URL
+	response, _ := conn.Send(context.Background(), mockMessage(), testToken)
+	assert.Equal(t, conn.RetryPolicy.MaxAttempts, response.Attempts)
+}
@@ -0,0 +1,152 @@
+package goapns
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+//Schedule is a Message that a Scheduler sends to its tokens at a later time.
+type Schedule struct {
+	//ID identifies the Schedule, pass it to Scheduler.Cancel.
+	ID string `json:"id"`
+	//Envelope is the complete Message, including its Header.
+	Envelope Envelope `json:"envelope"`
+	//Tokens are the device tokens the Message is sent to.
+	Tokens []string `json:"tokens"`
+	//At is the time at which the Message is sent next.
+	At time.Time `json:"at"`
+	//Cron is the recurrence of the Schedule, empty if it is sent only once.
+	Cron string `json:"cron,omitempty"`
+	//Location is the name of the time zone the Cron is evaluated in, like Europe/Berlin.
+	Location string `json:"location,omitempty"`
+}
+
+//ScheduleStore keeps the Schedules of a Scheduler, so they survive a restart.
+//MemoryScheduleStore and FileScheduleStore are provided, implement it to use your database.
+//Implementations must be safe for concurrent use.
+type ScheduleStore interface {
+	//Save adds the Schedule or replaces the one with the same ID.
+	Save(schedule Schedule) error
+	//Delete removes the Schedule. Nothing happens if it is not known.
+	Delete(id string) error
+	//Schedules returns every Schedule.
+	Schedules() ([]Schedule, error)
+}
+
+//MemoryScheduleStore is a ScheduleStore that keeps the Schedules in memory.
+type MemoryScheduleStore struct {
+	mutex     sync.RWMutex
+	schedules map[string]Schedule
+}
+
+//NewMemoryScheduleStore creates an empty MemoryScheduleStore.
+func NewMemoryScheduleStore() *MemoryScheduleStore {
+	return &MemoryScheduleStore{schedules: make(map[string]Schedule)}
+}
+
+//Save adds the Schedule or replaces the one with the same ID.
+func (s *MemoryScheduleStore) Save(schedule Schedule) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.schedules[schedule.ID] = schedule
+	return nil
+}
+
+//Delete removes the Schedule. Nothing happens if it is not known.
+func (s *MemoryScheduleStore) Delete(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.schedules, id)
+	return nil
+}
+
+//Schedules returns every Schedule ordered by the time at which it is sent next.
+func (s *MemoryScheduleStore) Schedules() ([]Schedule, error) {
+	s.mutex.RLock()
+	schedules := make([]Schedule, 0, len(s.schedules))
+	for _, schedule := range s.schedules {
+		schedules = append(schedules, schedule)
+	}
+	s.mutex.RUnlock()
+
+	sort.Slice(schedules, func(i, j int) bool {
+		if !schedules[i].At.Equal(schedules[j].At) {
+			return schedules[i].At.Before(schedules[j].At)
+		}
+		return schedules[i].ID < schedules[j].ID
+	})
+	return schedules, nil
+}
+
+//FileScheduleStore is a ScheduleStore that keeps the Schedules in memory and writes them
+//to a JSON file after every change. The file is replaced atomically, so it is
+//never left half written.
+type FileScheduleStore struct {
+	path string
+
+	//mutex serializes the changes together with writing the file.
+	mutex  sync.Mutex
+	memory *MemoryScheduleStore
+}
+
+//NewFileScheduleStore creates a FileScheduleStore that is stored at path.
+//The Schedules are read from the file if it exists.
+//It will return a *FileScheduleStore or an error. One of this is always nil.
+func NewFileScheduleStore(path string) (*FileScheduleStore, error) {
+	s := &FileScheduleStore{path: path, memory: NewMemoryScheduleStore()}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var schedules []Schedule
+	if err := json.Unmarshal(data, &schedules); err != nil {
+		return nil, err
+	}
+	for _, schedule := range schedules {
+		s.memory.schedules[schedule.ID] = schedule
+	}
+	return s, nil
+}
+
+//Save adds the Schedule or replaces the one with the same ID.
+func (s *FileScheduleStore) Save(schedule Schedule) error {
+	return s.change(func() error { return s.memory.Save(schedule) })
+}
+
+//Delete removes the Schedule. Nothing happens if it is not known.
+func (s *FileScheduleStore) Delete(id string) error {
+	return s.change(func() error { return s.memory.Delete(id) })
+}
+
+//Schedules returns every Schedule ordered by the time at which it is sent next.
+func (s *FileScheduleStore) Schedules() ([]Schedule, error) {
+	return s.memory.Schedules()
+}
+
+//change applies the change in memory and writes the file.
+func (s *FileScheduleStore) change(apply func() error) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := apply(); err != nil {
+		return err
+	}
+
+	schedules, _ := s.memory.Schedules()
+	data, err := json.MarshalIndent(schedules, "", "\t")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomically(s.path, data)
+}
@@ -0,0 +1,309 @@
+package goapns
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+//Scheduler errors.
+var (
+	ErrorScheduleNotFound = errors.New("There is no schedule with this ID.")
+	ErrorSchedulerClosed  = errors.New("The scheduler is closed and does not accept new schedules.")
+	ErrorScheduleNoNext   = errors.New("The cron expression does not match any time in the next five years.")
+)
+
+//Clock tells a Scheduler the time. Pass your own implementation to NewScheduler to control time in tests.
+type Clock interface {
+	//Now returns the current time.
+	Now() time.Time
+	//After returns a channel that receives the time once the duration has passed.
+	//It must fire immediately if the duration is not positive.
+	After(d time.Duration) <-chan time.Time
+}
+
+//systemClock is the Clock that uses the time package.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+//Scheduler sends Messages at a later time, once or recurring by a Cron expression.
+//The Schedules are kept in a ScheduleStore, a new Scheduler with the same store continues them.
+//A Schedule that became due while no Scheduler was running is sent as soon as one starts,
+//a recurring one only once for all the times it missed.
+//
+//Schedules are sent at most once: a Schedule is removed from the store, or moved to its next
+//time, before the Message is pushed. If the store fails, the Message is not sent and you get
+//one Response per token with the error of the store.
+//
+//You get one Response per token on the Responses() channel. Make sure to read from it,
+//otherwise the pushes block.
+type Scheduler struct {
+	sender    Sender
+	store     ScheduleStore
+	clock     Clock
+	responses chan Response
+
+	//mutex guards schedules and closed.
+	mutex     sync.Mutex
+	schedules map[string]*scheduled
+	closed    bool
+
+	wake    chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+	pending sync.WaitGroup
+}
+
+//scheduled is a Schedule together with its parsed Cron and location.
+type scheduled struct {
+	Schedule
+	cron     *Cron
+	location *time.Location
+}
+
+//NewScheduler creates a Scheduler that sends through the given Connection or ConnectionPool,
+//loads the Schedules of the store and starts to wait for the next one.
+//The clock may be nil to use the system time.
+//It returns an error if the Schedules can not be read or one of them is invalid.
+//It will return a *Scheduler or an error. One of this is always nil.
+func NewScheduler(sender Sender, store ScheduleStore, clock Clock) (*Scheduler, error) {
+	if clock == nil {
+		clock = systemClock{}
+	}
+
+	stored, err := store.Schedules()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Scheduler{
+		sender:    sender,
+		store:     store,
+		clock:     clock,
+		responses: make(chan Response),
+		schedules: make(map[string]*scheduled),
+		wake:      make(chan struct{}, 1),
+		done:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+	for _, schedule := range stored {
+		entry := &scheduled{Schedule: schedule, location: time.UTC}
+		if schedule.Location != "" {
+			if entry.location, err = time.LoadLocation(schedule.Location); err != nil {
+				return nil, err
+			}
+		}
+		if schedule.Cron != "" {
+			if entry.cron, err = ParseCron(schedule.Cron); err != nil {
+				return nil, err
+			}
+		}
+		s.schedules[schedule.ID] = entry
+	}
+
+	go s.run()
+	return s, nil
+}
+
+//Responses returns the channel that receives one Response for every token of a sent Schedule.
+//It is closed after Close() was called and every push is done.
+func (s *Scheduler) Responses() <-chan Response {
+	return s.responses
+}
+
+//At schedules the Message to be sent to the tokens at the given time. A time in the past
+//sends it right away. It returns the ID of the Schedule, or the error of Message.Envelope
+//or of the store.
+func (s *Scheduler) At(message *Message, at time.Time, tokens ...string) (string, error) {
+	envelope, err := message.Envelope()
+	if err != nil {
+		return "", err
+	}
+	return s.add(&scheduled{Schedule: Schedule{ID: newID(), Envelope: envelope, Tokens: tokens, At: at}, location: time.UTC})
+}
+
+//After schedules the Message to be sent to the tokens once the duration has passed.
+func (s *Scheduler) After(message *Message, d time.Duration, tokens ...string) (string, error) {
+	return s.At(message, s.clock.Now().Add(d), tokens...)
+}
+
+//Every schedules the Message to be sent to the tokens whenever the Cron expression matches,
+//evaluated in the location. A nil location means UTC. It returns the ID of the Schedule,
+//ErrorInvalidCron if the expression can not be parsed or ErrorScheduleNoNext if it never matches.
+func (s *Scheduler) Every(message *Message, spec string, location *time.Location, tokens ...string) (string, error) {
+	cron, err := ParseCron(spec)
+	if err != nil {
+		return "", err
+	}
+	if location == nil {
+		location = time.UTC
+	}
+	at := cron.Next(s.clock.Now().In(location))
+	if at.IsZero() {
+		return "", ErrorScheduleNoNext
+	}
+
+	envelope, err := message.Envelope()
+	if err != nil {
+		return "", err
+	}
+	schedule := Schedule{ID: newID(), Envelope: envelope, Tokens: tokens, At: at, Cron: spec, Location: location.String()}
+	return s.add(&scheduled{Schedule: schedule, cron: cron, location: location})
+}
+
+//Cancel removes the Schedule so it is not sent anymore.
+//It returns ErrorScheduleNotFound if there is no Schedule with the ID,
+//for example because it was sent already.
+func (s *Scheduler) Cancel(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, found := s.schedules[id]; !found {
+		return ErrorScheduleNotFound
+	}
+	if err := s.store.Delete(id); err != nil {
+		return err
+	}
+	delete(s.schedules, id)
+	s.signal()
+	return nil
+}
+
+//Schedules returns every Schedule that is waiting to be sent, ordered by the time at which it is sent next.
+func (s *Scheduler) Schedules() []Schedule {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	memory := NewMemoryScheduleStore()
+	for _, entry := range s.schedules {
+		memory.Save(entry.Schedule)
+	}
+	schedules, _ := memory.Schedules()
+	return schedules
+}
+
+//Close stops the Scheduler and waits until the pushes that were started are done.
+//The Schedules stay in the store and are continued by the next Scheduler.
+//Afterwards the Responses() channel is closed.
+func (s *Scheduler) Close() {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return
+	}
+	s.closed = true
+	s.mutex.Unlock()
+
+	close(s.done)
+	<-s.stopped
+	s.pending.Wait()
+	close(s.responses)
+}
+
+//add stores the Schedule and wakes the loop up, the Schedule may be the next one.
+func (s *Scheduler) add(entry *scheduled) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return "", ErrorSchedulerClosed
+	}
+	if err := s.store.Save(entry.Schedule); err != nil {
+		return "", err
+	}
+	s.schedules[entry.ID] = entry
+	s.signal()
+	return entry.ID, nil
+}
+
+//signal wakes the loop up without blocking. The caller must hold the mutex.
+func (s *Scheduler) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+//run waits for the next Schedule and sends it until the Scheduler is closed.
+func (s *Scheduler) run() {
+	defer close(s.stopped)
+
+	for {
+		var timer <-chan time.Time
+		if next, found := s.next(); found {
+			timer = s.clock.After(next.Sub(s.clock.Now()))
+		}
+
+		select {
+		case <-timer:
+			s.dispatch()
+		case <-s.wake:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+//next returns the time of the earliest Schedule and false if there is none.
+func (s *Scheduler) next() (time.Time, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var next time.Time
+	for _, entry := range s.schedules {
+		if next.IsZero() || entry.At.Before(next) {
+			next = entry.At
+		}
+	}
+	return next, !next.IsZero()
+}
+
+//dispatch pushes every Schedule that is due and removes it or moves it to its next time.
+func (s *Scheduler) dispatch() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := s.clock.Now()
+	for id, entry := range s.schedules {
+		if entry.At.After(now) {
+			continue
+		}
+
+		var err error
+		if next := s.following(entry, now); next.IsZero() {
+			delete(s.schedules, id)
+			err = s.store.Delete(id)
+		} else {
+			entry.At = next
+			err = s.store.Save(entry.Schedule)
+		}
+
+		message, decodeErr := entry.Envelope.Message()
+		if err == nil {
+			err = decodeErr
+		}
+		if err != nil {
+			s.pending.Add(len(entry.Tokens))
+			for _, token := range entry.Tokens {
+				go func(token string, err error) {
+					defer s.pending.Done()
+					s.responses <- newErrorResponse(message, token, err)
+				}(token, err)
+			}
+			continue
+		}
+		push(context.Background(), senderOf(s.sender), pushBatch{message: message, tokens: entry.Tokens}, s.responses, &s.pending)
+	}
+}
+
+//following returns the next time of a recurring Schedule after now, the zero time
+//if the Schedule is not recurring or its Cron does not match anymore.
+func (s *Scheduler) following(entry *scheduled, now time.Time) time.Time {
+	if entry.cron == nil {
+		return time.Time{}
+	}
+	return entry.cron.Next(now.In(entry.location))
+}
@@ -0,0 +1,123 @@
+package goapns_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tantalum73/Go-APNS"
+)
+
+func TestSchedulerAt(t *testing.T) {
+	conn, received, stop := recordingConnection(t)
+	defer stop()
+
+	clock := newFakeClock(time.Date(2024, time.January, 10, 8, 0, 0, 0, time.UTC))
+	scheduler, err := goapns.NewScheduler(conn, goapns.NewMemoryScheduleStore(), clock)
+	assert.Nil(t, err)
+	defer scheduler.Close()
+
+	id, err := scheduler.After(mockMessage(), time.Hour, testToken)
+	assert.Nil(t, err)
+	cancelled, err := scheduler.After(mockMessage(), 2*time.Hour, otherTestToken)
+	assert.Nil(t, err)
+	assert.Len(t, scheduler.Schedules(), 2)
+	assert.Equal(t, id, scheduler.Schedules()[0].ID)
+
+	clock.Advance(30 * time.Minute)
+	select {
+	case <-scheduler.Responses():
+		t.Fatal("the notification was sent too early")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	clock.Advance(30 * time.Minute)
+	response := <-scheduler.Responses()
+	assert.True(t, response.Sent())
+	assert.Equal(t, testToken, response.Token)
+	assert.Equal(t, "/3/device/"+testToken, (<-received).URL.Path)
+
+	assert.Nil(t, scheduler.Cancel(cancelled))
+	assert.Equal(t, goapns.ErrorScheduleNotFound, scheduler.Cancel(cancelled))
+	assert.Equal(t, goapns.ErrorScheduleNotFound, scheduler.Cancel(id))
+	assert.Empty(t, scheduler.Schedules())
+
+	clock.Advance(2 * time.Hour)
+	select {
+	case <-received:
+		t.Fatal("a cancelled notification was sent")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestSchedulerEvery(t *testing.T) {
+	conn, received, stop := recordingConnection(t)
+	defer stop()
+
+	clock := newFakeClock(time.Date(2024, time.January, 10, 8, 10, 0, 0, time.UTC))
+	scheduler, err := goapns.NewScheduler(conn, goapns.NewMemoryScheduleStore(), clock)
+	assert.Nil(t, err)
+	defer scheduler.Close()
+
+	id, err := scheduler.Every(mockMessage(), "*/15 * * * *", nil, testToken)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2024, time.January, 10, 8, 15, 0, 0, time.UTC), scheduler.Schedules()[0].At)
+
+	for i := 0; i < 3; i++ {
+		clock.Advance(15 * time.Minute)
+		response := <-scheduler.Responses()
+		assert.True(t, response.Sent())
+		<-received
+	}
+	assert.Equal(t, time.Date(2024, time.January, 10, 9, 0, 0, 0, time.UTC), scheduler.Schedules()[0].At)
+
+	assert.Nil(t, scheduler.Cancel(id))
+	_, err = scheduler.Every(mockMessage(), "0 0 30 2 *", nil, testToken)
+	assert.Equal(t, goapns.ErrorScheduleNoNext, err)
+	_, err = scheduler.Every(mockMessage(), "every minute", nil, testToken)
+	assert.Equal(t, goapns.ErrorInvalidCron, err)
+}
+
+func TestSchedulerPersists(t *testing.T) {
+	directory, _ := ioutil.TempDir("", "goapns")
+	defer os.RemoveAll(directory)
+	path := filepath.Join(directory, "schedules.json")
+
+	conn, received, stop := recordingConnection(t)
+	defer stop()
+	clock := newFakeClock(time.Date(2024, time.January, 10, 8, 0, 0, 0, time.UTC))
+
+	store, err := goapns.NewFileScheduleStore(path)
+	assert.Nil(t, err)
+	scheduler, err := goapns.NewScheduler(conn, store, clock)
+	assert.Nil(t, err)
+	message := mockMessage().Topic("com.example.app")
+	id, err := scheduler.After(message, time.Hour, testToken)
+	assert.Nil(t, err)
+	scheduler.Close()
+
+	//The process was not running when the Schedule became due.
+	clock.Advance(2 * time.Hour)
+
+	store, err = goapns.NewFileScheduleStore(path)
+	assert.Nil(t, err)
+	schedules, _ := store.Schedules()
+	assert.Len(t, schedules, 1)
+	assert.Equal(t, id, schedules[0].ID)
+	assert.Equal(t, "com.example.app", schedules[0].Envelope.Topic)
+
+	scheduler, err = goapns.NewScheduler(conn, store, clock)
+	assert.Nil(t, err)
+	response := <-scheduler.Responses()
+	assert.True(t, response.Sent())
+	assert.Equal(t, "/3/device/"+testToken, (<-received).URL.Path)
+	scheduler.Close()
+
+	schedules, _ = store.Schedules()
+	assert.Empty(t, schedules)
+	_, err = scheduler.After(message, time.Hour, testToken)
+	assert.Equal(t, goapns.ErrorSchedulerClosed, err)
+}
@@ -0,0 +1,38 @@
+//go:build go1.21
+
+package goapns
+
+import "log/slog"
+
+//NewSlogLogger returns a Logger that writes to the slog.Logger, the default one if it is nil.
+//Every message is written with the group goapns, so that the fields do not clash
+//with the fields of your service:
+//
+//	conn.Logger = goapns.NewSlogLogger(slog.New(slog.NewJSONHandler(os.Stderr, nil)))
+func NewSlogLogger(logger *slog.Logger) Logger {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return slogLogger{logger}
+}
+
+//slogLogger puts the fields of every message into the goapns group.
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+func (l slogLogger) Debug(msg string, keysAndValues ...interface{}) {
+	l.logger.Debug(msg, slog.Group("goapns", keysAndValues...))
+}
+
+func (l slogLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.logger.Info(msg, slog.Group("goapns", keysAndValues...))
+}
+
+func (l slogLogger) Warn(msg string, keysAndValues ...interface{}) {
+	l.logger.Warn(msg, slog.Group("goapns", keysAndValues...))
+}
+
+func (l slogLogger) Error(msg string, keysAndValues ...interface{}) {
+	l.logger.Error(msg, slog.Group("goapns", keysAndValues...))
+}
@@ -0,0 +1,32 @@
+//go:build go1.21
+
+package goapns_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tantalum73/Go-APNS"
+)
+
+func TestSlogLogger(t *testing.T) {
+	var output bytes.Buffer
+	handler := slog.NewJSONHandler(&output, &slog.HandlerOptions{Level: slog.LevelDebug})
+
+	logger := goapns.NewSlogLogger(slog.New(handler))
+	logger.Warn("notification was rejected", "token", "01", "status", 400)
+
+	var entry struct {
+		Level  string
+		Msg    string
+		Goapns map[string]interface{} `json:"goapns"`
+	}
+	assert.Nil(t, json.Unmarshal(output.Bytes(), &entry))
+	assert.Equal(t, "WARN", entry.Level)
+	assert.Equal(t, "notification was rejected", entry.Msg)
+	assert.Equal(t, "01", entry.Goapns["token"])
+	assert.Equal(t, float64(400), entry.Goapns["status"])
+}
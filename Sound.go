@@ -0,0 +1,43 @@
+package goapns
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+//ErrorInvalidSoundVolume is returned if the volume of a critical sound is not between 0.0 and 1.0.
+var ErrorInvalidSoundVolume = errors.New("The volume of a critical sound must be between 0.0 and 1.0.")
+
+//CriticalSound is a sound that is played even if the device is muted or in Do Not Disturb.
+//It is sent as a dictionary with the critical flag, its Name and its Volume instead of the
+//Sound string of the Payload. Your app needs the critical alerts entitlement for it.
+type CriticalSound struct {
+	//Name of a sound file in the app bundle or in the Library/Sounds folder of the app’s data container.
+	//If the sound file doesn’t exist or default is specified as the value, the default alert sound is played.
+	Name string
+
+	//Volume of the sound between 0.0 (silent) and 1.0 (full volume).
+	Volume float64
+}
+
+//NewCriticalSound creates a CriticalSound with the name of a sound file and its volume (0.0 - 1.0).
+func NewCriticalSound(name string, volume float64) *CriticalSound {
+	return &CriticalSound{Name: name, Volume: volume}
+}
+
+//Validate returns ErrorInvalidSoundVolume if the volume is out of range.
+func (s *CriticalSound) Validate() error {
+	if s.Volume < 0 || s.Volume > 1 {
+		return ErrorInvalidSoundVolume
+	}
+	return nil
+}
+
+//MarshalJSON builds the dictionary form of the sound.
+func (s *CriticalSound) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"critical": 1,
+		"name":     s.Name,
+		"volume":   s.Volume,
+	})
+}
@@ -0,0 +1,277 @@
+package goapns
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+//ErrorMissingTranslation is returned if there is no translation for a locale, its fallbacks and the default locale.
+var ErrorMissingTranslation = errors.New("There is no translation for the locale, its fallbacks or the default locale.")
+
+//Template renders the Title and Body of an alert in the language of the user.
+//Add a text/template source for every locale you support, for example
+//
+//	t := goapns.NewTemplate("en")
+//	t.Add("en", "New messages", `You have {{.Count}} new {{plural .Count "message" "messages"}}`)
+//	t.Add("de", "Neue Nachrichten", `Du hast {{.Count}} neue {{plural .Count "Nachricht" "Nachrichten"}}`)
+//
+//If there is no translation for a locale, its parents are tried (de-AT, then de),
+//as well as the fallbacks you set, and at last the DefaultLocale.
+//The plural function picks one of the forms by the PluralRule of the language.
+//Locales are compared case insensitive and an underscore is treated like a hyphen.
+type Template struct {
+	//DefaultLocale is used if there is no translation for a locale or its fallbacks.
+	DefaultLocale string
+
+	mutex        sync.RWMutex
+	translations map[string]translation
+	fallbacks    map[string][]string
+	pluralRules  map[string]PluralRule
+}
+
+//translation stores the parsed templates of one locale, a nil template renders an empty string.
+type translation struct {
+	title *template.Template
+	body  *template.Template
+}
+
+//NewTemplate creates a Template without translations that falls back to the defaultLocale.
+func NewTemplate(defaultLocale string) *Template {
+	return &Template{
+		DefaultLocale: defaultLocale,
+		translations:  make(map[string]translation),
+		fallbacks:     make(map[string][]string),
+		pluralRules:   make(map[string]PluralRule),
+	}
+}
+
+//Add parses the title and body templates of the locale and replaces a former translation.
+//Pass an empty string to leave title or body empty. It returns the error of text/template
+//if a template can not be parsed, the Template is unchanged in this case.
+func (t *Template) Add(locale string, title string, body string) error {
+	locale = normalizeLocale(locale)
+	functions := template.FuncMap{"plural": t.pluralFunction(locale)}
+
+	var parsed translation
+	var err error
+	if title != "" {
+		if parsed.title, err = template.New(locale + " title").Funcs(functions).Parse(title); err != nil {
+			return err
+		}
+	}
+	if body != "" {
+		if parsed.body, err = template.New(locale + " body").Funcs(functions).Parse(body); err != nil {
+			return err
+		}
+	}
+
+	t.mutex.Lock()
+	t.translations[locale] = parsed
+	t.mutex.Unlock()
+	return nil
+}
+
+//Fallback sets the locales that are tried, in order, if there is no translation for the locale.
+//They are tried before the parent of the locale, for example Fallback("pt-BR", "pt-PT")
+//uses pt-BR, pt-PT, pt and the DefaultLocale.
+func (t *Template) Fallback(locale string, fallbacks ...string) *Template {
+	normalized := make([]string, len(fallbacks))
+	for i, fallback := range fallbacks {
+		normalized[i] = normalizeLocale(fallback)
+	}
+
+	t.mutex.Lock()
+	t.fallbacks[normalizeLocale(locale)] = normalized
+	t.mutex.Unlock()
+	return t
+}
+
+//PluralRule sets the PluralRule of a language, like "de", and replaces the built-in one.
+func (t *Template) PluralRule(language string, rule PluralRule) *Template {
+	t.mutex.Lock()
+	t.pluralRules[normalizeLocale(language)] = rule
+	t.mutex.Unlock()
+	return t
+}
+
+//Render returns an Alert with Title and Body rendered for the locale.
+//It returns ErrorMissingTranslation if there is no translation to use
+//or the error of text/template if the data does not fit.
+func (t *Template) Render(locale string, data interface{}) (Alert, error) {
+	alert := NewAlert()
+
+	translation, found := t.lookup(locale)
+	if !found {
+		return alert, ErrorMissingTranslation
+	}
+
+	var err error
+	if alert.Title, err = execute(translation.title, data); err != nil {
+		return alert, err
+	}
+	if alert.Body, err = execute(translation.body, data); err != nil {
+		return alert, err
+	}
+	return alert, nil
+}
+
+//Message returns a copy of the Message with Title and Body rendered for the locale.
+//Every other value of the Message is kept, the Message itself is not changed.
+func (t *Template) Message(message *Message, locale string, data interface{}) (*Message, error) {
+	alert, err := t.Render(locale, data)
+	if err != nil {
+		return nil, err
+	}
+
+	//A shallow copy is enough, only the Alert is changed.
+	localized := *message
+	localized.Alert.Title = alert.Title
+	localized.Alert.Body = alert.Body
+	return &localized, nil
+}
+
+//Push sends the Message in the language of every user. tokens maps each device token to its locale.
+//The tokens are grouped by the translation they get, so the Message is rendered
+//and marshaled once per translation and pushed through s like Connection.Push does.
+//You get one Response per token, tokens without translation get ErrorMissingTranslation.
+//The responseChannel is closed after the last Response was delivered.
+func (t *Template) Push(s Sender, message *Message, tokens map[string]string, data interface{}, responseChannel chan Response) {
+	groups := make(map[string][]string)
+	for token, locale := range tokens {
+		resolved, _ := t.resolve(locale)
+		groups[resolved] = append(groups[resolved], token)
+	}
+
+	batches := make([]pushBatch, 0, len(groups))
+	for locale, group := range groups {
+		localized, err := t.Message(message, locale, data)
+		if err != nil {
+			batches = append(batches, pushBatch{message: message, tokens: group, err: err})
+			continue
+		}
+		batches = append(batches, pushBatch{message: localized, tokens: group})
+	}
+	pushAndClose(context.Background(), senderOf(s), responseChannel, batches...)
+}
+
+//lookup returns the translation that is used for the locale.
+func (t *Template) lookup(locale string) (translation, bool) {
+	resolved, found := t.resolve(locale)
+	if !found {
+		return translation{}, false
+	}
+
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	return t.translations[resolved], true
+}
+
+//resolve returns the first locale of the fallback chain that has a translation.
+//If there is none, the normalized locale and false are returned.
+func (t *Template) resolve(locale string) (string, bool) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	locale = normalizeLocale(locale)
+	var chain []string
+	for candidate := locale; candidate != ""; candidate = parentLocale(candidate) {
+		chain = append(chain, candidate)
+		chain = append(chain, t.fallbacks[candidate]...)
+	}
+	chain = append(chain, normalizeLocale(t.DefaultLocale))
+
+	for _, candidate := range chain {
+		if _, found := t.translations[candidate]; found {
+			return candidate, true
+		}
+	}
+	return locale, false
+}
+
+//pluralFunction returns the plural function of the templates of the locale.
+//It takes the count and the forms, the count may be any integer or float type.
+func (t *Template) pluralFunction(locale string) func(interface{}, ...string) (string, error) {
+	language := locale
+	if i := strings.Index(locale, "-"); i >= 0 {
+		language = locale[:i]
+	}
+
+	return func(count interface{}, forms ...string) (string, error) {
+		n, err := toInt(count)
+		if err != nil {
+			return "", err
+		}
+
+		t.mutex.RLock()
+		rule, found := t.pluralRules[language]
+		t.mutex.RUnlock()
+		if !found {
+			rule, found = pluralRules[language]
+		}
+		if !found {
+			rule = PluralRuleOne
+		}
+		return plural(rule, n, forms), nil
+	}
+}
+
+//execute renders the template, a nil template renders an empty string.
+func execute(tmpl *template.Template, data interface{}) (string, error) {
+	if tmpl == nil {
+		return "", nil
+	}
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, data); err != nil {
+		return "", err
+	}
+	return rendered.String(), nil
+}
+
+//normalizeLocale converts a locale like de_AT to de-at, so that locales can be compared.
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.Replace(locale, "_", "-", -1))
+}
+
+//parentLocale removes the last subtag of the locale, de-at becomes de and de becomes empty.
+func parentLocale(locale string) string {
+	if i := strings.LastIndex(locale, "-"); i >= 0 {
+		return locale[:i]
+	}
+	return ""
+}
+
+//toInt converts the count that is passed to plural in a template.
+func toInt(count interface{}) (int, error) {
+	switch n := count.(type) {
+	case int:
+		return n, nil
+	case int8:
+		return int(n), nil
+	case int16:
+		return int(n), nil
+	case int32:
+		return int(n), nil
+	case int64:
+		return int(n), nil
+	case uint:
+		return int(n), nil
+	case uint8:
+		return int(n), nil
+	case uint16:
+		return int(n), nil
+	case uint32:
+		return int(n), nil
+	case uint64:
+		return int(n), nil
+	case float32:
+		return int(n), nil
+	case float64:
+		return int(n), nil
+	}
+	return 0, fmt.Errorf("plural expects a number but got %v", count)
+}
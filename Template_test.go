@@ -0,0 +1,135 @@
+package goapns_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tantalum73/Go-APNS"
+)
+
+func mockTemplate(t *testing.T) *goapns.Template {
+	tmpl := goapns.NewTemplate("en")
+	assert.Nil(t, tmpl.Add("en", "Hello {{.Name}}", `{{.Count}} new {{plural .Count "message" "messages"}}`))
+	assert.Nil(t, tmpl.Add("de", "Hallo {{.Name}}", `{{.Count}} neue {{plural .Count "Nachricht" "Nachrichten"}}`))
+	assert.Nil(t, tmpl.Add("de-AT", "Servus {{.Name}}", ""))
+	assert.Nil(t, tmpl.Add("ru", "", `{{.Count}} {{plural .Count "сообщение" "сообщения" "сообщений"}}`))
+	return tmpl
+}
+
+type templateData struct {
+	Name  string
+	Count int
+}
+
+func TestTemplateRender(t *testing.T) {
+	tmpl := mockTemplate(t)
+
+	alert, err := tmpl.Render("de", templateData{"Anna", 1})
+	assert.Nil(t, err)
+	assert.Equal(t, "Hallo Anna", alert.Title)
+	assert.Equal(t, "1 neue Nachricht", alert.Body)
+
+	alert, err = tmpl.Render("en", templateData{"Anna", 3})
+	assert.Nil(t, err)
+	assert.Equal(t, "3 new messages", alert.Body)
+
+	for count, expected := range map[int]string{1: "1 сообщение", 3: "3 сообщения", 5: "5 сообщений", 12: "12 сообщений", 21: "21 сообщение"} {
+		alert, err = tmpl.Render("ru", templateData{Count: count})
+		assert.Nil(t, err)
+		assert.Equal(t, expected, alert.Body)
+	}
+
+	_, err = tmpl.Render("en", map[string]interface{}{"Count": "many"})
+	assert.Error(t, err)
+}
+
+func TestTemplateFallback(t *testing.T) {
+	tmpl := mockTemplate(t)
+
+	alert, err := tmpl.Render("de_AT", templateData{"Anna", 2})
+	assert.Nil(t, err)
+	assert.Equal(t, "Servus Anna", alert.Title)
+
+	//de-CH has no translation, de is used.
+	alert, err = tmpl.Render("de-CH", templateData{"Anna", 2})
+	assert.Nil(t, err)
+	assert.Equal(t, "2 neue Nachrichten", alert.Body)
+
+	//Unknown languages use the default locale.
+	alert, err = tmpl.Render("fr-FR", templateData{"Anna", 2})
+	assert.Nil(t, err)
+	assert.Equal(t, "Hello Anna", alert.Title)
+
+	tmpl.Fallback("lb", "de")
+	alert, err = tmpl.Render("lb-LU", templateData{"Anna", 2})
+	assert.Nil(t, err)
+	assert.Equal(t, "Hallo Anna", alert.Title)
+
+	_, err = goapns.NewTemplate("").Render("en", nil)
+	assert.Equal(t, goapns.ErrorMissingTranslation, err)
+
+	assert.Error(t, tmpl.Add("es", "{{.Name", ""))
+}
+
+func TestTemplatePluralRule(t *testing.T) {
+	tmpl := mockTemplate(t)
+	tmpl.PluralRule("en", goapns.PluralRuleZeroOne)
+
+	alert, err := tmpl.Render("en", templateData{Count: 0})
+	assert.Nil(t, err)
+	assert.Equal(t, "0 new message", alert.Body)
+}
+
+func TestTemplateMessage(t *testing.T) {
+	m := goapns.NewMessage().Title("title").Body("body").Badge(1).Custom("key", "value")
+
+	localized, err := mockTemplate(t).Message(m, "de", templateData{"Anna", 2})
+	assert.Nil(t, err)
+	assert.Equal(t, "Hallo Anna", localized.Alert.Title)
+	assert.Equal(t, 1, localized.Payload.Badge)
+	value, _ := localized.CustomValue("key")
+	assert.Equal(t, "value", value)
+
+	//The original Message is unchanged.
+	assert.Equal(t, "title", m.Alert.Title)
+}
+
+func TestTemplatePush(t *testing.T) {
+	conn := mockConnection(t)
+	devices := testTokens(5)
+
+	var mutex sync.Mutex
+	bodies := make(map[string]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		mutex.Lock()
+		bodies[strings.TrimPrefix(r.URL.Path, "/3/device/")] = string(body)
+		mutex.Unlock()
+	}))
+	defer server.Close()
+	conn.Host = server.URL
+
+	tmpl := mockTemplate(t)
+	tmpl.DefaultLocale = ""
+	tokens := map[string]string{devices[1]: "de", devices[2]: "de-CH", devices[3]: "en-US", devices[4]: "es"}
+	channel := make(chan goapns.Response, len(tokens))
+	tmpl.Push(conn, goapns.NewMessage(), tokens, templateData{"Anna", 1}, channel)
+
+	for response := range channel {
+		if response.Token == devices[4] {
+			assert.Equal(t, goapns.ErrorMissingTranslation, response.Error)
+		} else {
+			assert.Nil(t, response.Error)
+		}
+	}
+
+	assert.Len(t, bodies, 3)
+	assert.Contains(t, bodies[devices[1]], "Hallo Anna")
+	assert.Contains(t, bodies[devices[2]], "Hallo Anna")
+	assert.Contains(t, bodies[devices[3]], "Hello Anna")
+}
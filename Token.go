@@ -0,0 +1,131 @@
+package goapns
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"sync"
+	"time"
+)
+
+var (
+	//ErrorAuthKeyNotPEM is an error that reports that the auth key could not be decoded as PEM.
+	ErrorAuthKeyNotPEM = errors.New("The auth key is not PEM encoded. Please use the .p8 file from Apples Developer Center")
+	//ErrorAuthKeyNotECDSA is an error that reports that the auth key is in the wrong format.
+	ErrorAuthKeyNotECDSA = errors.New("Apparently the auth key is not an ECDSA private key, aborting.")
+)
+
+//TokenRefreshInterval is the age after which a provider token is regenerated.
+//Apple rejects tokens that are older than one hour and also rejects tokens
+//that are regenerated more often than every 20 minutes, so we stay in between.
+const TokenRefreshInterval = 50 * time.Minute
+
+//Token is a provider authentication token as it is used for token-based connections
+//to Apples servers. It holds the auth key (.p8), the Key ID and the Team ID and
+//signs a JSON Web Token (ES256) from it that is sent in the authorization header.
+//The JWT is cached and refreshed automatically once it is older than TokenRefreshInterval.
+//A Token is safe for concurrent use.
+type Token struct {
+	//KeyID is the 10-character identifier of the auth key, obtained from your developer account.
+	KeyID string
+
+	//TeamID is the 10-character Team ID you use for developing your apps.
+	TeamID string
+
+	//AuthKey is the private key of the .p8 file that was downloaded from your developer account.
+	AuthKey *ecdsa.PrivateKey
+
+	mutex    sync.Mutex
+	issuedAt time.Time
+	bearer   string
+}
+
+//NewToken creates a new Token from an auth key, its Key ID and your Team ID.
+func NewToken(authKey *ecdsa.PrivateKey, keyID string, teamID string) *Token {
+	return &Token{KeyID: keyID, TeamID: teamID, AuthKey: authKey}
+}
+
+//AuthKeyFromP8 loads a .p8 auth key file from a given path.
+func AuthKeyFromP8(filePath string) (*ecdsa.PrivateKey, error) {
+	p8Data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return AuthKeyFromBytes(p8Data)
+}
+
+//AuthKeyFromBytes decodes a PEM encoded PKCS#8 auth key, as it is stored in a .p8 file.
+func AuthKeyFromBytes(p8Data []byte) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode(p8Data)
+	if block == nil {
+		return nil, ErrorAuthKeyNotPEM
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	//ensure that private key is ECDSA
+	privateECDSAKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, ErrorAuthKeyNotECDSA
+	}
+	return privateECDSAKey, nil
+}
+
+//Bearer returns a signed JWT that can be used in the authorization header.
+//A cached JWT is returned as long as it is younger than TokenRefreshInterval,
+//a new one is generated otherwise.
+func (t *Token) Bearer() (string, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.bearer != "" && time.Since(t.issuedAt) < TokenRefreshInterval {
+		return t.bearer, nil
+	}
+	return t.generate()
+}
+
+//generate signs a new JWT and caches it. The caller must hold the mutex.
+func (t *Token) generate() (string, error) {
+	if t.AuthKey == nil {
+		return "", ErrorAuthKeyNotECDSA
+	}
+	issuedAt := time.Now()
+
+	header, err := json.Marshal(map[string]string{"alg": "ES256", "kid": t.KeyID})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]interface{}{"iss": t.TeamID, "iat": issuedAt.Unix()})
+	if err != nil {
+		return "", err
+	}
+
+	encoding := base64.RawURLEncoding
+	unsigned := encoding.EncodeToString(header) + "." + encoding.EncodeToString(claims)
+
+	digest := sha256.Sum256([]byte(unsigned))
+	r, s, err := ecdsa.Sign(rand.Reader, t.AuthKey, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	//JWS expects the signature as the concatenation of r and s,
+	//each padded to the size of the curve.
+	size := (t.AuthKey.Curve.Params().BitSize + 7) / 8
+	signature := make([]byte, 2*size)
+	r.FillBytes(signature[:size])
+	s.FillBytes(signature[size:])
+
+	t.bearer = unsigned + "." + encoding.EncodeToString(signature)
+	t.issuedAt = issuedAt
+	return t.bearer, nil
+}
@@ -0,0 +1,273 @@
+package goapns
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+//ErrorTokenInvalidated is returned instead of sending a notification to a device token
+//that is marked invalid in the TokenStore of the Connection.
+var ErrorTokenInvalidated = errors.New("The device token was invalidated because Apple reported it as unregistered or bad.")
+
+//TokenRecord describes a device token in a TokenStore.
+type TokenRecord struct {
+	//Token is the device token.
+	Token string `json:"token"`
+	//RegisteredAt is the time at which your app registered the token the last time.
+	RegisteredAt time.Time `json:"registered-at"`
+	//InvalidatedAt is the time since when the token is invalid. It is zero for valid tokens.
+	InvalidatedAt time.Time `json:"invalidated-at"`
+	//Reason is the reason Apple reported when the token was invalidated, like Unregistered.
+	Reason string `json:"reason,omitempty"`
+}
+
+//Valid returns true if the token was not invalidated.
+func (r TokenRecord) Valid() bool {
+	return r.InvalidatedAt.IsZero()
+}
+
+//TokenStore keeps track of your device tokens. Set it on a Connection to skip tokens
+//that are known to be invalid and to invalidate tokens automatically when Apple reports
+//them as Unregistered (HTTP 410) or BadDeviceToken.
+//An Unregistered token is only invalidated if it was not registered again after the
+//timestamp Apple sends, so a token your app registered anew keeps working.
+//
+//MemoryTokenStore and FileTokenStore are provided, implement it to use your database.
+//Implementations must be safe for concurrent use.
+type TokenStore interface {
+	//Register adds the token or updates its registration time. An invalidated token is valid again.
+	Register(token string, registeredAt time.Time) error
+	//Lookup returns the record of the token and true if the token is known.
+	Lookup(token string) (TokenRecord, bool, error)
+	//Invalidate marks the token invalid since the given time. Unknown tokens are added as invalid.
+	Invalidate(token string, invalidatedAt time.Time, reason string) error
+	//Remove deletes the token. Nothing happens if it is not known.
+	Remove(token string) error
+	//Tokens returns every valid token.
+	Tokens() ([]string, error)
+}
+
+//prune invalidates the token of the Response in the store if Apple reported it as
+//Unregistered or BadDeviceToken. Unregistered tokens are kept if they were registered
+//after the time Apple reported.
+func prune(store TokenStore, response Response) error {
+	switch {
+	case response.StatusCode == http.StatusGone || response.Error == ErrorUnregistered:
+		invalidatedAt := time.Now()
+		if response.TimestempNumber != 0 {
+			invalidatedAt = response.Timestamp()
+		}
+
+		record, found, err := store.Lookup(response.Token)
+		if err != nil {
+			return err
+		}
+		if found && record.RegisteredAt.After(invalidatedAt) {
+			//The app registered the token again after Apple stopped accepting it.
+			return nil
+		}
+		return store.Invalidate(response.Token, invalidatedAt, "Unregistered")
+
+	case response.Error == ErrorBadDeviceToken:
+		return store.Invalidate(response.Token, time.Now(), "BadDeviceToken")
+	}
+	return nil
+}
+
+//MemoryTokenStore is a TokenStore that keeps the tokens in memory.
+type MemoryTokenStore struct {
+	mutex   sync.RWMutex
+	records map[string]TokenRecord
+}
+
+//NewMemoryTokenStore creates an empty MemoryTokenStore.
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{records: make(map[string]TokenRecord)}
+}
+
+//Register adds the token or updates its registration time. An invalidated token is valid again.
+func (s *MemoryTokenStore) Register(token string, registeredAt time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.records[token] = TokenRecord{Token: token, RegisteredAt: registeredAt}
+	return nil
+}
+
+//Lookup returns the record of the token and true if the token is known.
+func (s *MemoryTokenStore) Lookup(token string) (TokenRecord, bool, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	record, found := s.records[token]
+	return record, found, nil
+}
+
+//Invalidate marks the token invalid since the given time. Unknown tokens are added as invalid.
+func (s *MemoryTokenStore) Invalidate(token string, invalidatedAt time.Time, reason string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	record := s.records[token]
+	record.Token = token
+	record.InvalidatedAt = invalidatedAt
+	record.Reason = reason
+	s.records[token] = record
+	return nil
+}
+
+//Remove deletes the token. Nothing happens if it is not known.
+func (s *MemoryTokenStore) Remove(token string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.records, token)
+	return nil
+}
+
+//Tokens returns every valid token in alphabetical order.
+func (s *MemoryTokenStore) Tokens() ([]string, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	tokens := make([]string, 0, len(s.records))
+	for token, record := range s.records {
+		if record.Valid() {
+			tokens = append(tokens, token)
+		}
+	}
+	sort.Strings(tokens)
+	return tokens, nil
+}
+
+//FileTokenStore is a TokenStore that keeps the tokens in memory and writes them
+//to a JSON file after every change. The file is replaced atomically, so it is
+//never left half written.
+type FileTokenStore struct {
+	path string
+
+	//mutex serializes the changes together with writing the file.
+	mutex  sync.Mutex
+	memory *MemoryTokenStore
+}
+
+//NewFileTokenStore creates a FileTokenStore that is stored at path.
+//The tokens are read from the file if it exists.
+//It will return a *FileTokenStore or an error. One of this is always nil.
+func NewFileTokenStore(path string) (*FileTokenStore, error) {
+	s := &FileTokenStore{path: path, memory: NewMemoryTokenStore()}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var records []TokenRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		s.memory.records[record.Token] = record
+	}
+	return s, nil
+}
+
+//Register adds the token or updates its registration time. An invalidated token is valid again.
+func (s *FileTokenStore) Register(token string, registeredAt time.Time) error {
+	return s.change(func(memory *MemoryTokenStore) error { return memory.Register(token, registeredAt) })
+}
+
+//Lookup returns the record of the token and true if the token is known.
+func (s *FileTokenStore) Lookup(token string) (TokenRecord, bool, error) {
+	return s.memory.Lookup(token)
+}
+
+//Invalidate marks the token invalid since the given time. Unknown tokens are added as invalid.
+func (s *FileTokenStore) Invalidate(token string, invalidatedAt time.Time, reason string) error {
+	return s.change(func(memory *MemoryTokenStore) error { return memory.Invalidate(token, invalidatedAt, reason) })
+}
+
+//Remove deletes the token. Nothing happens if it is not known.
+func (s *FileTokenStore) Remove(token string) error {
+	return s.change(func(memory *MemoryTokenStore) error { return memory.Remove(token) })
+}
+
+//Tokens returns every valid token in alphabetical order.
+func (s *FileTokenStore) Tokens() ([]string, error) {
+	return s.memory.Tokens()
+}
+
+//change applies the change to a copy of the records and writes the file.
+//The copy replaces the records in memory only if the file was written,
+//so that memory and file never disagree.
+func (s *FileTokenStore) change(apply func(memory *MemoryTokenStore) error) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.memory.mutex.RLock()
+	changed := &MemoryTokenStore{records: make(map[string]TokenRecord, len(s.memory.records))}
+	for token, record := range s.memory.records {
+		changed.records[token] = record
+	}
+	s.memory.mutex.RUnlock()
+
+	if err := apply(changed); err != nil {
+		return err
+	}
+	if err := s.write(changed.records); err != nil {
+		return err
+	}
+
+	s.memory.mutex.Lock()
+	s.memory.records = changed.records
+	s.memory.mutex.Unlock()
+	return nil
+}
+
+//write stores the records in a temporary file next to the file and renames it.
+func (s *FileTokenStore) write(changed map[string]TokenRecord) error {
+	records := make([]TokenRecord, 0, len(changed))
+	for _, record := range changed {
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Token < records[j].Token })
+
+	data, err := json.MarshalIndent(records, "", "\t")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomically(s.path, data)
+}
+
+//writeFileAtomically writes the data to a temporary file in the same directory
+//and renames it to path, so that readers see either the old or the new content.
+func writeFileAtomically(path string, data []byte) error {
+	temporary, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temporary.Name())
+
+	if _, err := temporary.Write(data); err != nil {
+		temporary.Close()
+		return err
+	}
+	if err := temporary.Sync(); err != nil {
+		temporary.Close()
+		return err
+	}
+	if err := temporary.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temporary.Name(), path)
+}
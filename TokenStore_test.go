@@ -0,0 +1,158 @@
+package goapns_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tantalum73/Go-APNS"
+)
+
+func TestMemoryTokenStore(t *testing.T) {
+	testTokenStore(t, goapns.NewMemoryTokenStore())
+}
+
+func TestFileTokenStore(t *testing.T) {
+	directory, err := ioutil.TempDir("", "tokens")
+	assert.Nil(t, err)
+	defer os.RemoveAll(directory)
+	path := filepath.Join(directory, "tokens.json")
+
+	store, err := goapns.NewFileTokenStore(path)
+	assert.Nil(t, err)
+	testTokenStore(t, store)
+
+	//The tokens are read from the file again.
+	reopened, err := goapns.NewFileTokenStore(path)
+	assert.Nil(t, err)
+	tokens, err := reopened.Tokens()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"01"}, tokens)
+	record, found, _ := reopened.Lookup("02")
+	assert.True(t, found)
+	assert.Equal(t, "Unregistered", record.Reason)
+
+	//No temporary files are left behind.
+	files, _ := ioutil.ReadDir(directory)
+	assert.Len(t, files, 1)
+
+	assert.Nil(t, ioutil.WriteFile(path, []byte("no json"), 0644))
+	_, err = goapns.NewFileTokenStore(path)
+	assert.Error(t, err)
+}
+
+func TestFileTokenStoreWriteError(t *testing.T) {
+	directory, err := ioutil.TempDir("", "tokens")
+	assert.Nil(t, err)
+	path := filepath.Join(directory, "tokens.json")
+
+	store, err := goapns.NewFileTokenStore(path)
+	assert.Nil(t, err)
+	assert.Nil(t, store.Register("01", time.Now()))
+
+	//The file can not be written anymore, the change must not be kept in memory.
+	os.RemoveAll(directory)
+	assert.Error(t, store.Register("02", time.Now()))
+	assert.Error(t, store.Invalidate("01", time.Now(), "Unregistered"))
+
+	_, found, _ := store.Lookup("02")
+	assert.False(t, found)
+	tokens, _ := store.Tokens()
+	assert.Equal(t, []string{"01"}, tokens)
+}
+
+func testTokenStore(t *testing.T, store goapns.TokenStore) {
+	now := time.Now()
+	assert.Nil(t, store.Register("01", now))
+	assert.Nil(t, store.Register("02", now))
+	assert.Nil(t, store.Register("03", now))
+
+	assert.Nil(t, store.Invalidate("02", now, "Unregistered"))
+	assert.Nil(t, store.Remove("03"))
+	assert.Nil(t, store.Remove("unknown"))
+
+	tokens, err := store.Tokens()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"01"}, tokens)
+
+	record, found, err := store.Lookup("02")
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.False(t, record.Valid())
+	assert.True(t, now.Equal(record.RegisteredAt))
+
+	_, found, _ = store.Lookup("03")
+	assert.False(t, found)
+}
+
+func TestConnectionTokenStore(t *testing.T) {
+	conn := mockConnection(t)
+	store := goapns.NewMemoryTokenStore()
+	conn.TokenStore = store
+	tokens := testTokens(5)
+
+	registeredAt := time.Unix(1500000000, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/3/device/" + tokens[0]:
+			t.Error("invalidated token was sent")
+		case "/3/device/" + tokens[1], "/3/device/" + tokens[2]:
+			//Apple stopped accepting the token after it was registered.
+			w.WriteHeader(http.StatusGone)
+			fmt.Fprintf(w, `{"reason":"Unregistered","timestamp":%v}`, registeredAt.Add(time.Hour).Unix()*1000)
+		case "/3/device/" + tokens[3]:
+			//The token was registered again after Apple stopped accepting it.
+			w.WriteHeader(http.StatusGone)
+			fmt.Fprintf(w, `{"reason":"Unregistered","timestamp":%v}`, registeredAt.Add(-time.Hour).Unix()*1000)
+		case "/3/device/" + tokens[4]:
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"reason":"BadDeviceToken"}`)
+		}
+	}))
+	defer server.Close()
+	conn.Host = server.URL
+
+	store.Invalidate(tokens[0], time.Now(), "Unregistered")
+	for _, token := range []string{tokens[1], tokens[3], tokens[4]} {
+		store.Register(token, registeredAt)
+	}
+
+	response, err := conn.Send(context.Background(), mockMessage(), tokens[0])
+	assert.Equal(t, goapns.ErrorTokenInvalidated, err)
+	assert.Equal(t, 0, response.StatusCode)
+
+	conn.Send(context.Background(), mockMessage(), tokens[1])
+	record, _, _ := store.Lookup(tokens[1])
+	assert.False(t, record.Valid())
+	assert.True(t, registeredAt.Add(time.Hour).Equal(record.InvalidatedAt))
+
+	//Unknown tokens are added as invalid.
+	conn.Send(context.Background(), mockMessage(), tokens[2])
+	record, found, _ := store.Lookup(tokens[2])
+	assert.True(t, found)
+	assert.False(t, record.Valid())
+
+	conn.Send(context.Background(), mockMessage(), tokens[3])
+	record, _, _ = store.Lookup(tokens[3])
+	assert.True(t, record.Valid())
+
+	conn.Send(context.Background(), mockMessage(), tokens[4])
+	record, _, _ = store.Lookup(tokens[4])
+	assert.False(t, record.Valid())
+	assert.Equal(t, "BadDeviceToken", record.Reason)
+
+	valid, _ := store.Tokens()
+	assert.Equal(t, []string{tokens[3]}, valid)
+
+	//Registering a token again makes it valid.
+	store.Register(tokens[1], time.Now())
+	valid, _ = store.Tokens()
+	assert.Equal(t, []string{tokens[1], tokens[3]}, valid)
+}
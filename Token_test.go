@@ -0,0 +1,119 @@
+package goapns_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tantalum73/Go-APNS"
+)
+
+func mockAuthKey(t *testing.T) (*ecdsa.PrivateKey, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.Nil(t, err)
+
+	dir, err := ioutil.TempDir("", "goapns")
+	assert.Nil(t, err)
+	path := filepath.Join(dir, "AuthKey_ABC123DEFG.p8")
+	err = ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600)
+	assert.Nil(t, err)
+	return key, path
+}
+
+//verifyJWT checks the ES256 signature of a JWT and returns its decoded header and claims.
+func verifyJWT(t *testing.T, key *ecdsa.PrivateKey, jwt string) (map[string]interface{}, map[string]interface{}) {
+	parts := strings.Split(jwt, ".")
+	assert.Len(t, parts, 3)
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	assert.Nil(t, err)
+	assert.Len(t, signature, 64)
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	r := new(big.Int).SetBytes(signature[:32])
+	s := new(big.Int).SetBytes(signature[32:])
+	assert.True(t, ecdsa.Verify(&key.PublicKey, digest[:], r, s), "JWT signature invalid")
+
+	var header, claims map[string]interface{}
+	headerJSON, _ := base64.RawURLEncoding.DecodeString(parts[0])
+	claimsJSON, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	assert.Nil(t, json.Unmarshal(headerJSON, &header))
+	assert.Nil(t, json.Unmarshal(claimsJSON, &claims))
+	return header, claims
+}
+
+func TestTokenBearer(t *testing.T) {
+	key, path := mockAuthKey(t)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	authKey, err := goapns.AuthKeyFromP8(path)
+	assert.Nil(t, err)
+
+	token := goapns.NewToken(authKey, "ABC123DEFG", "DEF123GHIJ")
+	bearer, err := token.Bearer()
+	assert.Nil(t, err)
+
+	header, claims := verifyJWT(t, key, bearer)
+	assert.Equal(t, "ES256", header["alg"])
+	assert.Equal(t, "ABC123DEFG", header["kid"])
+	assert.Equal(t, "DEF123GHIJ", claims["iss"])
+	assert.NotNil(t, claims["iat"])
+
+	//The token is cached until it needs to be refreshed.
+	cached, err := token.Bearer()
+	assert.Nil(t, err)
+	assert.Equal(t, bearer, cached)
+}
+
+func TestTokenAuthKeyNotPEM(t *testing.T) {
+	_, err := goapns.AuthKeyFromBytes([]byte("not a key"))
+	assert.Equal(t, goapns.ErrorAuthKeyNotPEM, err)
+}
+
+func TestTokenConnectionWrongPath(t *testing.T) {
+	conn, err := goapns.NewTokenConnection("example/nowhere.p8", "ABC123DEFG", "DEF123GHIJ")
+	assert.Error(t, err)
+	assert.Nil(t, conn)
+}
+
+func TestTokenConnectionAuthorizationHeader(t *testing.T) {
+	key, path := mockAuthKey(t)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	conn, err := goapns.NewTokenConnection(path, "ABC123DEFG", "DEF123GHIJ")
+	assert.Nil(t, err)
+	assert.NotNil(t, conn)
+	assert.Equal(t, goapns.HostDevelopment, conn.Host)
+	conn.HTTPClient = http.Client{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization := r.Header.Get("authorization")
+		assert.True(t, strings.HasPrefix(authorization, "bearer "))
+		_, claims := verifyJWT(t, key, strings.TrimPrefix(authorization, "bearer "))
+		assert.Equal(t, "DEF123GHIJ", claims["iss"])
+	}))
+	defer server.Close()
+
+	channel := make(chan goapns.Response, 1)
+
+	conn.Host = server.URL
+	conn.Push(mockMessage(), []string{testToken}, channel)
+	for response := range channel {
+		assert.True(t, response.Sent())
+	}
+}
@@ -0,0 +1,89 @@
+package goapns
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+//Tracer creates a Span for every request a Connection sends to Apples servers.
+//The package goapnsotel provides a Tracer for OpenTelemetry.
+type Tracer interface {
+	//Start creates a Span that is a child of the span in ctx, if there is one,
+	//and returns a context that contains the new Span.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+//Span describes one request to Apples servers.
+type Span interface {
+	//SetAttribute sets an attribute, the value is a string, an int or a bool.
+	SetAttribute(key string, value interface{})
+	//RecordError marks the Span as failed with the error.
+	RecordError(err error)
+	//End finishes the Span.
+	End()
+}
+
+//The attributes of the Span of a request.
+const (
+	SpanAttributeTopic     = "apns.topic"
+	SpanAttributePushType  = "apns.push_type"
+	SpanAttributeAPNSID    = "apns.id"
+	SpanAttributeStatus    = "apns.status"
+	SpanAttributeReason    = "apns.reason"
+	SpanAttributeTokenHash = "apns.token_hash"
+)
+
+//SpanName is the name of the Span of a request.
+const SpanName = "apns.send"
+
+//nopTracer creates spans that discard everything, it is used if no Tracer is set.
+type nopTracer struct{}
+
+func (nopTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	return ctx, nopSpan{}
+}
+
+type nopSpan struct{}
+
+func (nopSpan) SetAttribute(key string, value interface{}) {}
+func (nopSpan) RecordError(err error)                      {}
+func (nopSpan) End()                                       {}
+
+//startSpan starts the Span of a request with the attributes that are known before it is sent.
+func startSpan(ctx context.Context, tracer Tracer, message *Message, token string) (context.Context, Span) {
+	ctx, span := tracer.Start(ctx, SpanName)
+
+	pushType := message.InferredPushType()
+	span.SetAttribute(SpanAttributePushType, string(pushType))
+	if topic := message.Header.topic(pushType); topic != "" {
+		span.SetAttribute(SpanAttributeTopic, topic)
+	}
+	span.SetAttribute(SpanAttributeTokenHash, hashToken(token))
+	return ctx, span
+}
+
+//endSpan sets the attributes of the Response and ends the Span.
+func endSpan(span Span, response Response) {
+	if response.APNSID != "" {
+		span.SetAttribute(SpanAttributeAPNSID, response.APNSID)
+	}
+	if response.StatusCode != 0 {
+		span.SetAttribute(SpanAttributeStatus, response.StatusCode)
+	}
+	if response.Reason != "" {
+		span.SetAttribute(SpanAttributeReason, response.Reason)
+	}
+	if response.Error != nil {
+		span.RecordError(response.Error)
+	}
+	span.End()
+}
+
+//hashToken returns the first 16 hexadecimal digits of the SHA-256 of the token.
+//Traces are often kept longer and shown to more people than the tokens themselves,
+//the hash is enough to find the requests of one device.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:8])
+}
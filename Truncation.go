@@ -0,0 +1,136 @@
+package goapns
+
+import "unicode"
+
+//DefaultEllipsis is the ellipsis you may want to pass to TruncateBody.
+const DefaultEllipsis = "…"
+
+//TruncateBody enables the truncation of the alert body. If the JSON of the Message
+//exceeds the maximum payload size of its push type, the body is shortened until it fits
+//and the ellipsis is appended to it. The Message itself is left untouched, only the JSON
+//that is sent is affected. Response.Truncated tells you how many bytes were cut.
+//
+//The body is cut between user-perceived characters, so that neither a multi-byte
+//UTF-8 sequence nor an emoji with skin tone or a flag are torn apart.
+func (m *Message) TruncateBody(ellipsis string) *Message {
+	m.truncate = true
+	m.ellipsis = ellipsis
+	return m
+}
+
+//NoTruncation disables the truncation that was enabled by TruncateBody.
+func (m *Message) NoTruncation() *Message {
+	m.truncate = false
+	m.ellipsis = ""
+	return m
+}
+
+//encodedMessage is the JSON of a Message as it is sent to Apples servers.
+type encodedMessage struct {
+	dataToSend []byte
+	//truncated is the number of bytes that were cut from the alert body.
+	truncated int
+}
+
+//encode marshals the Message and truncates the body if necessary and enabled.
+func (m *Message) encode() (encodedMessage, error) {
+	dataToSend, err := m.marshal()
+	if err != nil || !m.truncate {
+		return encodedMessage{dataToSend: dataToSend}, err
+	}
+
+	limit := m.InferredPushType().MaxPayloadSize()
+	if len(dataToSend) <= limit {
+		return encodedMessage{dataToSend: dataToSend}, nil
+	}
+
+	//Searching the longest prefix of the body that fits. Only the boundaries
+	//of user-perceived characters are candidates, without the full body (which is too large).
+	body := m.Alert.Body
+	cuts := append([]int{0}, graphemeBoundaries(body)...)
+	cuts = cuts[:len(cuts)-1]
+
+	shortened := *m
+	fits := func(length int) ([]byte, bool) {
+		shortened.Alert.Body = body[:length] + m.ellipsis
+		data, err := shortened.marshal()
+		return data, err == nil && len(data) <= limit
+	}
+
+	low, high := 0, len(cuts)-1
+	var best []byte
+	bestLength := -1
+	for low <= high {
+		middle := (low + high) / 2
+		if data, ok := fits(cuts[middle]); ok {
+			best, bestLength = data, cuts[middle]
+			low = middle + 1
+		} else {
+			high = middle - 1
+		}
+	}
+
+	if best == nil {
+		//Even without a body it is too large, Validate reports it.
+		return encodedMessage{dataToSend: dataToSend}, nil
+	}
+	return encodedMessage{dataToSend: best, truncated: len(body) - bestLength}, nil
+}
+
+//graphemeBoundaries returns the byte offsets at which the user-perceived characters
+//of the string end. It approximates extended grapheme clusters: combining marks,
+//variation selectors, emoji modifiers and tags stay with their base character,
+//characters joined by a zero width joiner stay together and so do pairs of regional
+//indicators (flags) and CR LF.
+func graphemeBoundaries(s string) []int {
+	var boundaries []int
+	var previous rune
+	regionalIndicators := 0
+
+	for offset, r := range s {
+		if offset > 0 && !joinsPrevious(previous, r, regionalIndicators) {
+			boundaries = append(boundaries, offset)
+		}
+
+		if isRegionalIndicator(r) {
+			regionalIndicators++
+		} else {
+			regionalIndicators = 0
+		}
+		previous = r
+	}
+	if len(s) > 0 {
+		boundaries = append(boundaries, len(s))
+	}
+	return boundaries
+}
+
+//joinsPrevious reports if the rune belongs to the same user-perceived character as the previous rune.
+func joinsPrevious(previous rune, r rune, regionalIndicators int) bool {
+	switch {
+	case previous == '\r' && r == '\n':
+		return true
+	case previous == '\u200d', r == '\u200d':
+		//zero width joiner
+		return true
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc):
+		return true
+	case r >= 0xFE00 && r <= 0xFE0F, r >= 0xE0100 && r <= 0xE01EF:
+		//variation selectors
+		return true
+	case r >= 0x1F3FB && r <= 0x1F3FF:
+		//emoji skin tone modifiers
+		return true
+	case r >= 0xE0020 && r <= 0xE007F:
+		//tags, used in subdivision flags
+		return true
+	case isRegionalIndicator(r) && regionalIndicators%2 == 1:
+		//the second regional indicator of a flag
+		return true
+	}
+	return false
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
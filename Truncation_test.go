@@ -0,0 +1,97 @@
+package goapns_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tantalum73/Go-APNS"
+)
+
+func decodedBody(t *testing.T, data []byte) string {
+	var decoded struct {
+		APS struct {
+			Alert struct {
+				Body string `json:"body"`
+			} `json:"alert"`
+		} `json:"aps"`
+	}
+	assert.Nil(t, json.Unmarshal(data, &decoded))
+	return decoded.APS.Alert.Body
+}
+
+func TestTruncateBody(t *testing.T) {
+	body := strings.Repeat("a", 5000)
+	m := goapns.NewMessage().Title("Title").Body(body).TruncateBody(goapns.DefaultEllipsis)
+
+	data, err := m.MarshalJSON()
+	assert.Nil(t, err)
+	assert.True(t, len(data) <= goapns.MaxPayloadSize)
+	//The limit is used as well as possible.
+	assert.True(t, len(data) > goapns.MaxPayloadSize-len(goapns.DefaultEllipsis))
+	assert.True(t, strings.HasSuffix(decodedBody(t, data), goapns.DefaultEllipsis))
+	assert.Nil(t, m.Validate())
+
+	//The Message itself is not changed.
+	assert.Equal(t, body, m.Alert.Body)
+}
+
+func TestTruncateBodyNotNecessary(t *testing.T) {
+	m := mockMessage().TruncateBody(goapns.DefaultEllipsis)
+	data, err := m.MarshalJSON()
+	assert.Nil(t, err)
+	assert.Equal(t, "body", decodedBody(t, data))
+}
+
+func TestTruncateBodyDisabled(t *testing.T) {
+	m := goapns.NewMessage().Body(strings.Repeat("a", 5000))
+	assert.Equal(t, goapns.ErrorPayloadTooLarge, m.Validate())
+
+	m.TruncateBody("...").NoTruncation()
+	assert.Equal(t, goapns.ErrorPayloadTooLarge, m.Validate())
+}
+
+func TestTruncateBodyKeepsCharactersTogether(t *testing.T) {
+	for _, character := range []string{"ü", "👍🏽", "🇩🇪", "👨‍👩‍👧", "é"} {
+		m := goapns.NewMessage().Body(strings.Repeat(character, 3000)).TruncateBody("")
+
+		data, err := m.MarshalJSON()
+		assert.Nil(t, err)
+
+		truncated := decodedBody(t, data)
+		assert.True(t, utf8.ValidString(truncated))
+		assert.NotEmpty(t, truncated)
+		assert.Equal(t, "", strings.Replace(truncated, character, "", -1), "%v was torn apart", character)
+	}
+}
+
+func TestTruncateBodyVoIP(t *testing.T) {
+	m := goapns.NewMessage().Body(strings.Repeat("a", 6000)).PushType(goapns.PushTypeVoIP).TruncateBody("")
+
+	data, err := m.MarshalJSON()
+	assert.Nil(t, err)
+	assert.True(t, len(data) > goapns.MaxPayloadSize)
+	assert.True(t, len(data) <= goapns.MaxVoIPPayloadSize)
+}
+
+func TestTruncateBodyResponse(t *testing.T) {
+	conn := mockConnection(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	conn.Host = server.URL
+
+	m := goapns.NewMessage().Body(strings.Repeat("a", 5000)).TruncateBody(goapns.DefaultEllipsis)
+	response, err := conn.Send(context.Background(), m, testToken)
+	assert.Nil(t, err)
+	assert.True(t, response.Truncated > 900)
+
+	response, err = conn.Send(context.Background(), mockMessage(), testToken)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, response.Truncated)
+}
@@ -0,0 +1,101 @@
+package goapns
+
+import (
+	"encoding/hex"
+	"regexp"
+)
+
+//Maximum sizes of the payload in bytes as they are enforced by Apple.
+const (
+	//MaxPayloadSize is the maximum size of a regular notification.
+	MaxPayloadSize = 4096
+	//MaxVoIPPayloadSize is the maximum size of a VoIP notification.
+	MaxVoIPPayloadSize = 5120
+)
+
+//apnsIDPattern matches the canonical form of a UUID: 8-4-4-4-12 hexadecimal digits.
+var apnsIDPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+//MaxPayloadSize returns the maximum size of the payload in bytes for this push type.
+func (p PushType) MaxPayloadSize() int {
+	if p == PushTypeVoIP {
+		return MaxVoIPPayloadSize
+	}
+	return MaxPayloadSize
+}
+
+//Validate checks the Message the way Apples servers do, without sending it.
+//It returns the same errors you would get in a Response otherwise:
+//ErrorPayloadTooLarge if the JSON exceeds the size of its push type (4 KB, 5 KB for VoIP),
+//ErrorPayloadEmpty if there is nothing to deliver, ErrorBadPriority for unknown priorities,
+//ErrorBadMessageID if the APNSID is not a UUID as well as
+//ErrorPushTypePriorityMismatch and ErrorPushTypeTopicMismatch if the header does not match the push type.
+//Errors of the Payload, like ErrorInvalidInterruptionLevel, are returned too.
+//Connection validates every Message before it is sent.
+func (m *Message) Validate() error {
+	encoded, err := m.encode()
+	if err != nil {
+		return err
+	}
+	return m.validate(encoded.dataToSend)
+}
+
+//validate checks the Message with its already marshaled JSON.
+func (m *Message) validate(dataToSend []byte) error {
+	pushType := m.InferredPushType()
+
+	if err := m.Header.validate(pushType); err != nil {
+		return err
+	}
+
+	//A priority of 0 is not sent, Apple assumes PriorityHigh then.
+	switch m.Header.Priority {
+	case 0, PriorityHigh, PriorityLow, PriorityLowest:
+	default:
+		return ErrorBadPriority
+	}
+
+	if m.Header.APNSID != "" {
+		if err := ValidateAPNSID(m.Header.APNSID); err != nil {
+			return err
+		}
+	}
+
+	if len(dataToSend) > pushType.MaxPayloadSize() {
+		return ErrorPayloadTooLarge
+	}
+
+	//Alerts and background notifications need something in the aps dictionary,
+	//other push types may deliver custom data only.
+	apsEmpty := m.Alert.IsEmpty() && len(m.Payload.MapInto(make(map[string]interface{}))) == 0
+	if apsEmpty && (pushType == PushTypeAlert || pushType == PushTypeBackground || len(m.custom) == 0) {
+		return ErrorPayloadEmpty
+	}
+	return nil
+}
+
+//MinTokenLength is the minimum length of a device token in hexadecimal digits.
+//Device tokens have 32 bytes, Apple may make them longer but not shorter.
+const MinTokenLength = 64
+
+//ValidateToken checks that a device token is a hexadecimal string of at least MinTokenLength digits.
+//It returns ErrorMissingDeviceToken if it is empty and ErrorBadDeviceToken if it is malformed.
+func ValidateToken(token string) error {
+	if token == "" {
+		return ErrorMissingDeviceToken
+	}
+	//DecodeString fails for an odd length as well.
+	if _, err := hex.DecodeString(token); err != nil || len(token) < MinTokenLength {
+		return ErrorBadDeviceToken
+	}
+	return nil
+}
+
+//ValidateAPNSID checks that the apns-id is a UUID in canonical form and returns ErrorBadMessageID if it is not.
+//An empty apns-id is not valid, leave it out of the Header to let Apple create one.
+func ValidateAPNSID(id string) error {
+	if !apnsIDPattern.MatchString(id) {
+		return ErrorBadMessageID
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package goapns_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tantalum73/Go-APNS"
+)
+
+func TestValidateValidMessage(t *testing.T) {
+	assert.Nil(t, mockMessage().Validate())
+	assert.Nil(t, mockMessage().APNSID("123e4567-e89b-12d3-a456-426655440000").Validate())
+	assert.Nil(t, goapns.NewMessage().ContentAvailable().Validate())
+}
+
+func TestValidatePayloadSize(t *testing.T) {
+	m := goapns.NewMessage().Body(strings.Repeat("a", 4500))
+	assert.Equal(t, goapns.ErrorPayloadTooLarge, m.Validate())
+
+	//VoIP notifications may be larger.
+	m.PushType(goapns.PushTypeVoIP)
+	assert.Nil(t, m.Validate())
+
+	m.Body(strings.Repeat("a", 5200))
+	assert.Equal(t, goapns.ErrorPayloadTooLarge, m.Validate())
+}
+
+func TestValidatePayloadEmpty(t *testing.T) {
+	assert.Equal(t, goapns.ErrorPayloadEmpty, goapns.NewMessage().Validate())
+	assert.Equal(t, goapns.ErrorPayloadEmpty, goapns.NewMessage().Custom("key", "value").Validate())
+
+	//VoIP notifications may deliver custom data only.
+	assert.Nil(t, goapns.NewMessage().PushType(goapns.PushTypeVoIP).Custom("key", "value").Validate())
+	assert.Equal(t, goapns.ErrorPayloadEmpty, goapns.NewMessage().PushType(goapns.PushTypeVoIP).Validate())
+}
+
+func TestValidateHeader(t *testing.T) {
+	assert.Equal(t, goapns.ErrorBadMessageID, mockMessage().APNSID("102").Validate())
+	assert.Equal(t, goapns.ErrorPushTypePriorityMismatch, goapns.NewMessage().ContentAvailable().PriorityHigh().Validate())
+
+	m := mockMessage()
+	m.Header.Priority = 7
+	assert.Equal(t, goapns.ErrorBadPriority, m.Validate())
+}
+
+func TestValidateUnsetPriority(t *testing.T) {
+	m := mockMessage()
+	m.Header.Priority = 0
+	assert.Nil(t, m.Validate())
+
+	m = goapns.NewMessage().ContentAvailable()
+	m.Header.Priority = 0
+	assert.Equal(t, goapns.ErrorPushTypePriorityMismatch, m.Validate())
+}
+
+func TestPrioritySent(t *testing.T) {
+	conn := mockConnection(t)
+
+	var priority string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		priority = r.Header.Get("apns-priority")
+	}))
+	defer server.Close()
+	conn.Host = server.URL
+
+	tests := map[int]string{0: "", goapns.PriorityHigh: "", goapns.PriorityLow: "5", goapns.PriorityLowest: "1"}
+	for value, header := range tests {
+		m := mockMessage()
+		m.Header.Priority = value
+		response, err := conn.Send(context.Background(), m, testToken)
+		assert.Nil(t, err)
+		assert.True(t, response.Sent())
+		assert.Equal(t, header, priority, "priority %v", value)
+	}
+
+	//A Message that was not created by NewMessage has no priority.
+	m := &goapns.Message{}
+	m.Alert.Body = "body"
+	_, err := conn.Send(context.Background(), m, testToken)
+	assert.Nil(t, err)
+	assert.Equal(t, "", priority)
+}
+
+func TestValidateAPNSID(t *testing.T) {
+	assert.Nil(t, goapns.ValidateAPNSID("123e4567-e89b-12d3-a456-426655440000"))
+	assert.Equal(t, goapns.ErrorBadMessageID, goapns.ValidateAPNSID(""))
+	assert.Equal(t, goapns.ErrorBadMessageID, goapns.ValidateAPNSID("123e4567e89b12d3a456426655440000"))
+}
+
+func TestValidateToken(t *testing.T) {
+	assert.Nil(t, goapns.ValidateToken("a26f0000c05286ee6f31756b1b9d05b4a37ad512fabbe266dd21357b376f0e0e"))
+	assert.Equal(t, goapns.ErrorMissingDeviceToken, goapns.ValidateToken(""))
+	assert.Equal(t, goapns.ErrorBadDeviceToken, goapns.ValidateToken("123"))
+	assert.Equal(t, goapns.ErrorBadDeviceToken, goapns.ValidateToken("not-a-token!"))
+	//Tokens have at least 32 bytes, Apple may make them longer.
+	assert.Equal(t, goapns.ErrorBadDeviceToken, goapns.ValidateToken(testToken[:62]))
+	assert.Equal(t, goapns.ErrorBadDeviceToken, goapns.ValidateToken(testToken+"0"))
+	assert.Nil(t, goapns.ValidateToken(testToken+testToken))
+}
+
+func TestValidateBeforePush(t *testing.T) {
+	conn := mockConnection(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("invalid notification was sent")
+	}))
+	defer server.Close()
+	conn.Host = server.URL
+
+	tokens := []string{testToken, "xyz"}
+	channel := make(chan goapns.Response, len(tokens))
+	conn.Push(mockMessage().APNSID("102"), tokens, channel)
+
+	for response := range channel {
+		assert.Equal(t, goapns.ErrorBadMessageID, response.Error)
+		assert.Equal(t, 0, response.StatusCode)
+	}
+
+	channel = make(chan goapns.Response, 1)
+	conn.Push(mockMessage(), []string{"xyz"}, channel)
+	for response := range channel {
+		assert.Equal(t, goapns.ErrorBadDeviceToken, response.Error)
+	}
+}
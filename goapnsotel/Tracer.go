@@ -0,0 +1,59 @@
+//Package goapnsotel creates the spans of Go-APNS with OpenTelemetry.
+//
+//	conn.Tracer = goapnsotel.NewTracer(otel.Tracer("goapns"))
+//	conn.PushContext(ctx, message, tokens, responseChannel)
+//
+//Every request to Apples servers becomes a client span that is a child of the span in ctx.
+package goapnsotel
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tantalum73/Go-APNS"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+//NewTracer returns a goapns.Tracer that creates its spans with the OpenTelemetry tracer.
+func NewTracer(tracer trace.Tracer) goapns.Tracer {
+	return otelTracer{tracer}
+}
+
+type otelTracer struct {
+	tracer trace.Tracer
+}
+
+func (t otelTracer) Start(ctx context.Context, name string) (context.Context, goapns.Span) {
+	ctx, span := t.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+	return ctx, otelSpan{span}
+}
+
+type otelSpan struct {
+	span trace.Span
+}
+
+func (s otelSpan) SetAttribute(key string, value interface{}) {
+	switch v := value.(type) {
+	case string:
+		s.span.SetAttributes(attribute.String(key, v))
+	case int:
+		s.span.SetAttributes(attribute.Int(key, v))
+	case int64:
+		s.span.SetAttributes(attribute.Int64(key, v))
+	case bool:
+		s.span.SetAttributes(attribute.Bool(key, v))
+	default:
+		s.span.SetAttributes(attribute.String(key, fmt.Sprint(v)))
+	}
+}
+
+func (s otelSpan) RecordError(err error) {
+	s.span.RecordError(err)
+	s.span.SetStatus(codes.Error, err.Error())
+}
+
+func (s otelSpan) End() {
+	s.span.End()
+}
@@ -0,0 +1,66 @@
+package goapnsotel_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tantalum73/Go-APNS"
+	"github.com/tantalum73/Go-APNS/goapnsotel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracer(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	tokens := []string{strings.Repeat("01", 32), strings.Repeat("02", 32)}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("apns-id", "123e4567-e89b-12d3-a456-426655440000")
+		if r.URL.Path == "/3/device/"+tokens[1] {
+			w.WriteHeader(http.StatusGone)
+			w.Write([]byte(`{"reason":"Unregistered"}`))
+		}
+	}))
+	defer server.Close()
+
+	conn := &goapns.Connection{Host: server.URL, Tracer: goapnsotel.NewTracer(provider.Tracer("test"))}
+	message := goapns.NewMessage().Body("body").Topic("com.example.app")
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "parent")
+	channel := make(chan goapns.Response, len(tokens))
+	conn.PushContext(ctx, message, tokens, channel)
+	for range channel {
+	}
+	parent.End()
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 3)
+	for _, span := range spans[:2] {
+		assert.Equal(t, goapns.SpanName, span.Name())
+		assert.Equal(t, parent.SpanContext().SpanID(), span.Parent().SpanID())
+
+		attributes := make(map[attribute.Key]attribute.Value)
+		for _, kv := range span.Attributes() {
+			attributes[kv.Key] = kv.Value
+		}
+		assert.Equal(t, "com.example.app", attributes[goapns.SpanAttributeTopic].AsString())
+		assert.Equal(t, "alert", attributes[goapns.SpanAttributePushType].AsString())
+		assert.Equal(t, "123e4567-e89b-12d3-a456-426655440000", attributes[goapns.SpanAttributeAPNSID].AsString())
+		assert.Len(t, attributes[goapns.SpanAttributeTokenHash].AsString(), 16)
+
+		if attributes[goapns.SpanAttributeStatus].AsInt64() == http.StatusGone {
+			assert.Equal(t, "Unregistered", attributes[goapns.SpanAttributeReason].AsString())
+			assert.Equal(t, codes.Error, span.Status().Code)
+		} else {
+			assert.Equal(t, int64(http.StatusOK), attributes[goapns.SpanAttributeStatus].AsInt64())
+			assert.Equal(t, codes.Unset, span.Status().Code)
+		}
+	}
+}
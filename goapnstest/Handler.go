@@ -0,0 +1,333 @@
+package goapnstest
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tantalum73/Go-APNS"
+)
+
+//providerTokenLifetime is the time after which Apple rejects a provider token as expired.
+const providerTokenLifetime = time.Hour
+
+//Notification is a request the Server received.
+type Notification struct {
+	//Token is the device token of the request path.
+	Token string
+	//APNSID is the apns-id of the request or the one the Server created.
+	APNSID string
+	//Topic is the apns-topic header.
+	Topic string
+	//PushType is the apns-push-type header.
+	PushType goapns.PushType
+	//Priority is the apns-priority header, 0 if it was not sent.
+	Priority int
+	//Expiration is the apns-expiration header, zero if it was not sent.
+	Expiration time.Time
+	//CollapseID is the apns-collapse-id header.
+	CollapseID string
+	//Authorization is the authorization header, it contains the provider token.
+	Authorization string
+	//ClientCertificate is the certificate the client authenticated with, nil if there was none.
+	ClientCertificate *x509.Certificate
+	//RemoteAddr is the address of the connection the request was sent on.
+	RemoteAddr string
+	//Payload is the JSON body of the request.
+	Payload []byte
+	//Reply is what the Server answered.
+	Reply Reply
+	//ReceivedAt is the time the request arrived.
+	ReceivedAt time.Time
+}
+
+//Message decodes the Payload and the headers of the Notification into a goapns.Message.
+func (n Notification) Message() (*goapns.Message, error) {
+	envelope := goapns.Envelope{
+		APNSID:     n.APNSID,
+		Priority:   n.Priority,
+		Topic:      n.Topic,
+		CollapseID: n.CollapseID,
+		PushType:   n.PushType,
+		Payload:    n.Payload,
+	}
+	if !n.Expiration.IsZero() {
+		envelope.Expiration = n.Expiration.Unix()
+	}
+	return envelope.Message()
+}
+
+//handle answers a request like Apple does.
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	latency := s.latency
+	s.mutex.Unlock()
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	notification := Notification{
+		Token:         strings.TrimPrefix(r.URL.Path, "/3/device/"),
+		APNSID:        r.Header.Get("apns-id"),
+		Topic:         r.Header.Get("apns-topic"),
+		PushType:      goapns.PushType(r.Header.Get("apns-push-type")),
+		CollapseID:    r.Header.Get("apns-collapse-id"),
+		Authorization: r.Header.Get("authorization"),
+		RemoteAddr:    r.RemoteAddr,
+		ReceivedAt:    time.Now(),
+	}
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		notification.ClientCertificate = r.TLS.PeerCertificates[0]
+	}
+	if notification.APNSID == "" {
+		notification.APNSID = newUUID()
+	}
+
+	reply, rejected := s.validate(r, &notification)
+	if !rejected {
+		reply = s.scripted(notification.Token)
+	}
+	notification.Reply = reply
+
+	s.mutex.Lock()
+	s.notifications = append(s.notifications, notification)
+	s.mutex.Unlock()
+
+	w.Header().Set("apns-id", notification.APNSID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(reply.Status)
+	if reply.Status != http.StatusOK {
+		body := map[string]interface{}{"reason": reply.Reason}
+		if !reply.Timestamp.IsZero() {
+			body["timestamp"] = reply.Timestamp.UnixNano() / int64(time.Millisecond)
+		}
+		json.NewEncoder(w).Encode(body)
+	}
+}
+
+//validate reads the request into the notification and returns the Reply
+//and true if Apple would reject it.
+func (s *Server) validate(r *http.Request, notification *Notification) (Reply, bool) {
+	if r.Method != http.MethodPost {
+		return NewReply("MethodNotAllowed"), true
+	}
+	if !strings.HasPrefix(r.URL.Path, "/3/device/") {
+		return NewReply("BadPath"), true
+	}
+	for _, header := range []string{"apns-id", "apns-topic", "apns-push-type", "apns-priority", "apns-expiration", "apns-collapse-id", "authorization"} {
+		if len(r.Header[http.CanonicalHeaderKey(header)]) > 1 {
+			return NewReply("DuplicateHeaders"), true
+		}
+	}
+
+	if reason := s.authenticate(notification); reason != "" {
+		return NewReply(reason), true
+	}
+	if reason := s.validateHeader(r, notification); reason != "" {
+		return NewReply(reason), true
+	}
+
+	if notification.Token == "" {
+		return NewReply("MissingDeviceToken"), true
+	}
+	if goapns.ValidateToken(notification.Token) != nil {
+		return NewReply("BadDeviceToken"), true
+	}
+
+	maxSize := notification.PushType.MaxPayloadSize()
+	payload, err := ioutil.ReadAll(io.LimitReader(r.Body, int64(maxSize)+1))
+	if err != nil {
+		return NewReply("InternalServerError"), true
+	}
+	notification.Payload = payload
+	if len(payload) > maxSize {
+		return NewReply("PayloadTooLarge"), true
+	}
+	var dictionary map[string]json.RawMessage
+	if len(payload) == 0 || json.Unmarshal(payload, &dictionary) != nil || dictionary["aps"] == nil {
+		return NewReply("PayloadEmpty"), true
+	}
+
+	if s.throttled(notification.Token, notification.ReceivedAt) {
+		return NewReply("TooManyRequests"), true
+	}
+	return Reply{}, false
+}
+
+//authenticate returns the reason if the request has neither a client certificate nor a valid provider token.
+func (s *Server) authenticate(notification *Notification) string {
+	if notification.Authorization == "" {
+		if notification.ClientCertificate == nil {
+			return "MissingProviderToken"
+		}
+		return ""
+	}
+
+	fields := strings.Fields(notification.Authorization)
+	if len(fields) != 2 || !strings.EqualFold(fields[0], "bearer") {
+		return "InvalidProviderToken"
+	}
+	parts := strings.Split(fields[1], ".")
+	if len(parts) != 3 {
+		return "InvalidProviderToken"
+	}
+
+	var header struct {
+		Algorithm string `json:"alg"`
+		KeyID     string `json:"kid"`
+	}
+	var claims struct {
+		Issuer   string `json:"iss"`
+		IssuedAt int64  `json:"iat"`
+	}
+	if decodeJWTPart(parts[0], &header) != nil || decodeJWTPart(parts[1], &claims) != nil {
+		return "InvalidProviderToken"
+	}
+	if header.Algorithm != "ES256" || header.KeyID == "" || claims.Issuer == "" {
+		return "InvalidProviderToken"
+	}
+	if s.AuthKey != nil && !verifyES256(s.AuthKey, parts[0]+"."+parts[1], parts[2]) {
+		return "InvalidProviderToken"
+	}
+	if time.Since(time.Unix(claims.IssuedAt, 0)) > providerTokenLifetime {
+		return "ExpiredProviderToken"
+	}
+	return ""
+}
+
+//validateHeader reads the headers into the notification and returns the reason if one is invalid.
+func (s *Server) validateHeader(r *http.Request, notification *Notification) string {
+	if goapns.ValidateAPNSID(notification.APNSID) != nil {
+		return "BadMessageId"
+	}
+
+	if priority := r.Header.Get("apns-priority"); priority != "" {
+		value, err := strconv.Atoi(priority)
+		if err != nil || (value != goapns.PriorityHigh && value != goapns.PriorityLow && value != goapns.PriorityLowest) {
+			return "BadPriority"
+		}
+		notification.Priority = value
+	}
+
+	if expiration := r.Header.Get("apns-expiration"); expiration != "" {
+		value, err := strconv.ParseInt(expiration, 10, 64)
+		if err != nil {
+			return "BadExpirationDate"
+		}
+		if value != 0 {
+			notification.Expiration = time.Unix(value, 0)
+		}
+	}
+
+	if len(notification.CollapseID) > 64 {
+		return "BadCollapseId"
+	}
+
+	switch notification.PushType {
+	case "", goapns.PushTypeAlert, goapns.PushTypeVoIP, goapns.PushTypeComplication, goapns.PushTypeFileProvider,
+		goapns.PushTypeMDM, goapns.PushTypeLocation, goapns.PushTypeLiveActivity, goapns.PushTypePushToTalk:
+	case goapns.PushTypeBackground:
+		if notification.Priority == 0 || notification.Priority == goapns.PriorityHigh {
+			return "BadPriority"
+		}
+	default:
+		return "InvalidPushType"
+	}
+
+	if notification.Topic == "" {
+		//Provider tokens are not bound to a topic, Apple needs to know it.
+		if notification.Authorization != "" {
+			return "MissingTopic"
+		}
+		return ""
+	}
+	if s.Topic != "" {
+		suffix := notification.PushType.TopicSuffix()
+		if !strings.HasSuffix(notification.Topic, suffix) {
+			return "BadTopic"
+		}
+		if strings.TrimSuffix(notification.Topic, suffix) != s.Topic {
+			return "TopicDisallowed"
+		}
+	}
+	return ""
+}
+
+//throttled records the request and returns true if there were too many to the token.
+func (s *Server) throttled(token string, now time.Time) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.throttleLimit <= 0 {
+		return false
+	}
+	recent := s.requests[token][:0]
+	for _, received := range s.requests[token] {
+		if now.Sub(received) < s.throttleSpan {
+			recent = append(recent, received)
+		}
+	}
+	s.requests[token] = append(recent, now)
+	return len(recent) >= s.throttleLimit
+}
+
+//scripted returns the next scripted Reply for the token, Success if there is none.
+func (s *Server) scripted(token string) Reply {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, key := range []string{token, AnyToken} {
+		if replies := s.scripts[key]; len(replies) > 0 {
+			s.scripts[key] = replies[1:]
+			return replies[0]
+		}
+	}
+	return Success
+}
+
+//decodeJWTPart decodes a base64url encoded JSON part of a JWT.
+func decodeJWTPart(part string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+//verifyES256 checks the JWS signature, which is r and s concatenated.
+func verifyES256(key *ecdsa.PublicKey, unsigned string, signature string) bool {
+	data, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || len(data)%2 != 0 {
+		return false
+	}
+	size := len(data) / 2
+	r := new(big.Int).SetBytes(data[:size])
+	sig := new(big.Int).SetBytes(data[size:])
+
+	digest := sha256.Sum256([]byte(unsigned))
+	return ecdsa.Verify(key, digest[:], r, sig)
+}
+
+//newUUID creates a random UUID like the apns-id Apple creates.
+func newUUID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,71 @@
+package goapnstest
+
+import (
+	"net/http"
+	"time"
+)
+
+//Reply is a response of the Server. Use NewReply or Unregistered to create one with the
+//status code Apple uses for the reason, or set the values yourself.
+type Reply struct {
+	//Status is the HTTP status code.
+	Status int
+	//Reason is sent in the JSON body if the Status is not 200.
+	Reason string
+	//Timestamp is sent in the JSON body if it is not zero, Apple does that for status 410.
+	Timestamp time.Time
+}
+
+//reasonStatus maps the reasons of Apple to their HTTP status codes.
+var reasonStatus = map[string]int{
+	"BadCollapseId":               http.StatusBadRequest,
+	"BadDeviceToken":              http.StatusBadRequest,
+	"BadExpirationDate":           http.StatusBadRequest,
+	"BadMessageId":                http.StatusBadRequest,
+	"BadPriority":                 http.StatusBadRequest,
+	"BadTopic":                    http.StatusBadRequest,
+	"DeviceTokenNotForTopic":      http.StatusBadRequest,
+	"DuplicateHeaders":            http.StatusBadRequest,
+	"IdleTimeout":                 http.StatusBadRequest,
+	"InvalidPushType":             http.StatusBadRequest,
+	"MissingDeviceToken":          http.StatusBadRequest,
+	"MissingTopic":                http.StatusBadRequest,
+	"PayloadEmpty":                http.StatusBadRequest,
+	"TopicDisallowed":             http.StatusBadRequest,
+	"BadCertificate":              http.StatusForbidden,
+	"BadCertificateEnvironment":   http.StatusForbidden,
+	"ExpiredProviderToken":        http.StatusForbidden,
+	"Forbidden":                   http.StatusForbidden,
+	"InvalidProviderToken":        http.StatusForbidden,
+	"MissingProviderToken":        http.StatusForbidden,
+	"BadPath":                     http.StatusNotFound,
+	"MethodNotAllowed":            http.StatusMethodNotAllowed,
+	"Unregistered":                http.StatusGone,
+	"PayloadTooLarge":             http.StatusRequestEntityTooLarge,
+	"TooManyProviderTokenUpdates": http.StatusTooManyRequests,
+	"TooManyRequests":             http.StatusTooManyRequests,
+	"InternalServerError":         http.StatusInternalServerError,
+	"ServiceUnavailable":          http.StatusServiceUnavailable,
+	"Shutdown":                    http.StatusServiceUnavailable,
+}
+
+//NewReply creates a Reply with the reason and the status code Apple sends with it.
+//Unknown reasons are sent with status 400.
+func NewReply(reason string) Reply {
+	status, found := reasonStatus[reason]
+	if !found {
+		status = http.StatusBadRequest
+	}
+	return Reply{Status: status, Reason: reason}
+}
+
+//Unregistered creates the Reply Apple sends for a device token that is no longer
+//valid since the given time.
+func Unregistered(since time.Time) Reply {
+	reply := NewReply("Unregistered")
+	reply.Timestamp = since
+	return reply
+}
+
+//Success is the Reply for a notification that was accepted.
+var Success = Reply{Status: http.StatusOK}
@@ -0,0 +1,293 @@
+//Package goapnstest provides a fake of Apples push notification servers for your tests.
+//
+//The Server speaks HTTP/2 over TLS like Apple does, validates the headers and payloads
+//of the requests, authenticates them with a client certificate or a provider token and
+//answers with the status codes and reasons Apple uses. You can script the replies per token,
+//slow it down, throttle it and let it close its connections with a GOAWAY frame.
+//
+//	server := goapnstest.NewServer()
+//	defer server.Close()
+//
+//	conn := server.Connection(certificate)
+//	server.Script("<token>", goapnstest.Unregistered(time.Now()))
+//	response, err := conn.Send(ctx, message, "<token>")
+package goapnstest
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/tantalum73/Go-APNS"
+	"golang.org/x/net/http2"
+)
+
+//AnyToken can be passed to Script to script the replies for every token.
+const AnyToken = ""
+
+//Server is an in-process fake of Apples push notification servers.
+//Set Topic and AuthKey before you send the first notification.
+type Server struct {
+	//URL is the host of the Server, use it as Host of a goapns.Connection.
+	URL string
+
+	//Topic is the bundle ID that notifications must be sent to. Topics with the suffix
+	//of their push type are accepted as well. If it is empty, every topic is accepted.
+	Topic string
+
+	//AuthKey is used to verify the signature of provider tokens. If it is nil,
+	//only the format of provider tokens is checked.
+	AuthKey *ecdsa.PublicKey
+
+	listener    net.Listener
+	certificate *x509.Certificate
+	tlsConfig   *tls.Config
+
+	mutex         sync.Mutex
+	current       *generation
+	generations   []*generation
+	notifications []Notification
+	scripts       map[string][]Reply
+	latency       time.Duration
+	throttleLimit int
+	throttleSpan  time.Duration
+	requests      map[string][]time.Time
+}
+
+//generation is an http.Server that serves the connections which were accepted
+//since the last GOAWAY.
+type generation struct {
+	server      *http.Server
+	connections chan net.Conn
+	closed      chan struct{}
+	closeOnce   sync.Once
+}
+
+//NewServer starts a Server on a random port of the loopback interface with a
+//self-signed certificate. Close it when you are done.
+func NewServer() *Server {
+	certificate, tlsCertificate, err := selfSignedCertificate()
+	if err != nil {
+		panic("goapnstest: creating the certificate failed: " + err.Error())
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic("goapnstest: listening failed: " + err.Error())
+	}
+
+	s := &Server{
+		URL:         "https://" + listener.Addr().String(),
+		listener:    listener,
+		certificate: certificate,
+		scripts:     make(map[string][]Reply),
+		requests:    make(map[string][]time.Time),
+	}
+	s.tlsConfig = &tls.Config{
+		Certificates: []tls.Certificate{tlsCertificate},
+		NextProtos:   []string{http2.NextProtoTLS},
+		//Apple authenticates with client certificates, they are checked by the handler.
+		ClientAuth: tls.RequestClientCert,
+	}
+
+	s.current = s.startGeneration()
+	go s.accept()
+	return s
+}
+
+//Close shuts the Server down and closes every connection.
+func (s *Server) Close() {
+	s.listener.Close()
+
+	s.mutex.Lock()
+	generations := s.generations
+	s.mutex.Unlock()
+	for _, g := range generations {
+		g.close()
+		g.server.Close()
+	}
+}
+
+//Certificate returns the self-signed certificate of the Server.
+func (s *Server) Certificate() *x509.Certificate {
+	return s.certificate
+}
+
+//TLSConfig returns a tls.Config for clients that trusts the Server and presents
+//the client certificates, if you pass some.
+func (s *Server) TLSConfig(certificates ...tls.Certificate) *tls.Config {
+	roots := x509.NewCertPool()
+	roots.AddCert(s.certificate)
+	return &tls.Config{RootCAs: roots, Certificates: certificates}
+}
+
+//Connection returns a goapns.Connection that sends to the Server over HTTP/2.
+//Pass the certificate to authenticate with, or set a Token on the Connection instead.
+func (s *Server) Connection(certificates ...tls.Certificate) *goapns.Connection {
+	conn := &goapns.Connection{Host: s.URL}
+	conn.HTTPClient = http.Client{Transport: &http2.Transport{TLSClientConfig: s.TLSConfig(certificates...)}}
+	if len(certificates) > 0 {
+		conn.Certificate = certificates[0]
+	}
+	return conn
+}
+
+//Script sets the replies for the next notifications to the token, they are used in order.
+//Use AnyToken to script the replies for every token, replies of a token are used first.
+//A notification that Apple would reject gets the rejection instead of a scripted reply.
+//When the script is used up, notifications are accepted again.
+func (s *Server) Script(token string, replies ...Reply) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.scripts[token] = append(s.scripts[token], replies...)
+}
+
+//SetLatency delays every reply by the duration.
+func (s *Server) SetLatency(latency time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.latency = latency
+}
+
+//SetThrottle lets the Server reply TooManyRequests if more than limit notifications
+//are sent to the same token within the duration. A limit of 0 disables it.
+func (s *Server) SetThrottle(limit int, duration time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.throttleLimit = limit
+	s.throttleSpan = duration
+	s.requests = make(map[string][]time.Time)
+}
+
+//GoAway sends a GOAWAY frame on every open connection, like Apple does when it
+//closes a connection. Requests in flight are answered, new requests need a new connection.
+func (s *Server) GoAway() {
+	s.mutex.Lock()
+	old := s.current
+	s.current = s.startGeneration()
+	s.mutex.Unlock()
+
+	old.close()
+	go old.server.Shutdown(context.Background())
+}
+
+//Notifications returns every request the Server received, in the order they arrived.
+func (s *Server) Notifications() []Notification {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]Notification(nil), s.notifications...)
+}
+
+//Reset forgets the received notifications, the scripts and the throttled requests.
+func (s *Server) Reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.notifications = nil
+	s.scripts = make(map[string][]Reply)
+	s.requests = make(map[string][]time.Time)
+}
+
+//startGeneration starts an http.Server for the connections that are accepted from now on.
+//The caller must hold the mutex.
+func (s *Server) startGeneration() *generation {
+	g := &generation{
+		connections: make(chan net.Conn),
+		closed:      make(chan struct{}),
+	}
+	g.server = &http.Server{Handler: http.HandlerFunc(s.handle), TLSConfig: s.tlsConfig}
+	s.generations = append(s.generations, g)
+
+	go g.server.ServeTLS(generationListener{g, s.listener.Addr()}, "", "")
+	return g
+}
+
+//accept hands every accepted connection to the current generation.
+func (s *Server) accept() {
+	for {
+		connection, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		for handed := false; !handed; {
+			s.mutex.Lock()
+			g := s.current
+			s.mutex.Unlock()
+
+			select {
+			case g.connections <- connection:
+				handed = true
+			case <-g.closed:
+				//A GOAWAY happened in the meantime, the next generation takes it.
+			}
+		}
+	}
+}
+
+func (g *generation) close() {
+	g.closeOnce.Do(func() { close(g.closed) })
+}
+
+//generationListener is the net.Listener of a generation.
+type generationListener struct {
+	generation *generation
+	address    net.Addr
+}
+
+func (l generationListener) Accept() (net.Conn, error) {
+	select {
+	case connection := <-l.generation.connections:
+		return connection, nil
+	case <-l.generation.closed:
+		return nil, errors.New("goapnstest: listener closed")
+	}
+}
+
+func (l generationListener) Close() error {
+	l.generation.close()
+	return nil
+}
+
+func (l generationListener) Addr() net.Addr {
+	return l.address
+}
+
+//selfSignedCertificate creates a certificate for 127.0.0.1 and localhost.
+func selfSignedCertificate() (*x509.Certificate, tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, tls.Certificate{}, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "goapnstest"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		DNSNames:              []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, tls.Certificate{}, err
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, tls.Certificate{}, err
+	}
+	return certificate, tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: certificate}, nil
+}
@@ -0,0 +1,189 @@
+package goapnstest_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tantalum73/Go-APNS"
+	"github.com/tantalum73/Go-APNS/goapnstest"
+	"golang.org/x/net/http2"
+)
+
+const token = "a26f0000c05286ee6f31756b1b9d05b4a37ad512fabbe266dd21357b376f0e0e"
+
+func clientCertificate(t *testing.T) tls.Certificate {
+	certificate, err := goapns.CertificateFromP12("../example/certificate-valid-encrypted.p12", "password")
+	assert.Nil(t, err)
+	return certificate
+}
+
+func TestServerCertificate(t *testing.T) {
+	server := goapnstest.NewServer()
+	defer server.Close()
+	conn := server.Connection(clientCertificate(t))
+
+	message := goapns.NewMessage().Title("title").Body("body").Badge(1).Topic("com.example.app").CollapseID("score")
+	response, err := conn.Send(context.Background(), message, token)
+	assert.Nil(t, err)
+	assert.True(t, response.Sent())
+	assert.NotEmpty(t, response.APNSID)
+
+	notifications := server.Notifications()
+	assert.Len(t, notifications, 1)
+	n := notifications[0]
+	assert.Equal(t, token, n.Token)
+	assert.Equal(t, response.APNSID, n.APNSID)
+	assert.Equal(t, "com.example.app", n.Topic)
+	assert.Equal(t, goapns.PushTypeAlert, n.PushType)
+	assert.Equal(t, "score", n.CollapseID)
+	assert.NotNil(t, n.ClientCertificate)
+	assert.Equal(t, goapnstest.Success, n.Reply)
+
+	received, err := n.Message()
+	assert.Nil(t, err)
+	assert.Equal(t, message.Alert, received.Alert)
+	assert.Equal(t, message.Payload, received.Payload)
+}
+
+func TestServerProviderToken(t *testing.T) {
+	server := goapnstest.NewServer()
+	defer server.Close()
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	server.AuthKey = &key.PublicKey
+
+	conn := server.Connection()
+	_, err := conn.Send(context.Background(), goapns.NewMessage().Body("body").Topic("com.example.app"), token)
+	assert.Equal(t, goapns.ErrorMissingProviderToken, err)
+
+	conn.Token = goapns.NewToken(key, "KEYID", "TEAMID")
+	_, err = conn.Send(context.Background(), goapns.NewMessage().Body("body").Topic("com.example.app"), token)
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(server.Notifications()[1].Authorization, "bearer "))
+
+	_, err = conn.Send(context.Background(), goapns.NewMessage().Body("body"), token)
+	assert.Equal(t, goapns.ErrorMissingTopic, err)
+
+	other, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	conn.Token = goapns.NewToken(other, "KEYID", "TEAMID")
+	_, err = conn.Send(context.Background(), goapns.NewMessage().Body("body").Topic("com.example.app"), token)
+	assert.Equal(t, goapns.ErrorInvalidProviderToken, err)
+}
+
+func TestServerValidation(t *testing.T) {
+	server := goapnstest.NewServer()
+	defer server.Close()
+	server.Topic = "com.example.app"
+	client := http.Client{Transport: &http2.Transport{TLSClientConfig: server.TLSConfig(clientCertificate(t))}}
+
+	send := func(method string, path string, header map[string]string, body string) goapnstest.Reply {
+		request, _ := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		for key, value := range header {
+			request.Header.Set(key, value)
+		}
+		response, err := client.Do(request)
+		if !assert.Nil(t, err) {
+			return goapnstest.Reply{}
+		}
+		response.Body.Close()
+		notifications := server.Notifications()
+		reply := notifications[len(notifications)-1].Reply
+		assert.Equal(t, reply.Status, response.StatusCode)
+		return reply
+	}
+
+	payload := `{"aps":{"alert":"body"}}`
+	assert.Equal(t, goapnstest.Success, send("POST", "/3/device/"+token, nil, payload))
+	assert.Equal(t, "MethodNotAllowed", send("GET", "/3/device/"+token, nil, "").Reason)
+	assert.Equal(t, "BadPath", send("POST", "/2/device/"+token, nil, payload).Reason)
+	assert.Equal(t, "MissingDeviceToken", send("POST", "/3/device/", nil, payload).Reason)
+	assert.Equal(t, "BadDeviceToken", send("POST", "/3/device/xyz", nil, payload).Reason)
+	assert.Equal(t, "BadMessageId", send("POST", "/3/device/"+token, map[string]string{"apns-id": "102"}, payload).Reason)
+	assert.Equal(t, "BadPriority", send("POST", "/3/device/"+token, map[string]string{"apns-priority": "7"}, payload).Reason)
+	assert.Equal(t, "BadPriority", send("POST", "/3/device/"+token, map[string]string{"apns-push-type": "background"}, payload).Reason)
+	assert.Equal(t, "InvalidPushType", send("POST", "/3/device/"+token, map[string]string{"apns-push-type": "fax"}, payload).Reason)
+	assert.Equal(t, "BadExpirationDate", send("POST", "/3/device/"+token, map[string]string{"apns-expiration": "tomorrow"}, payload).Reason)
+	assert.Equal(t, "BadCollapseId", send("POST", "/3/device/"+token, map[string]string{"apns-collapse-id": strings.Repeat("a", 65)}, payload).Reason)
+	assert.Equal(t, "TopicDisallowed", send("POST", "/3/device/"+token, map[string]string{"apns-topic": "com.example.other"}, payload).Reason)
+	assert.Equal(t, "BadTopic", send("POST", "/3/device/"+token, map[string]string{"apns-topic": "com.example.app", "apns-push-type": "voip"}, payload).Reason)
+	assert.Equal(t, goapnstest.Success, send("POST", "/3/device/"+token, map[string]string{"apns-topic": "com.example.app.voip", "apns-push-type": "voip"}, payload))
+	assert.Equal(t, "PayloadEmpty", send("POST", "/3/device/"+token, nil, "").Reason)
+	assert.Equal(t, "PayloadEmpty", send("POST", "/3/device/"+token, nil, `{"key":"value"}`).Reason)
+	assert.Equal(t, "PayloadTooLarge", send("POST", "/3/device/"+token, nil, `{"aps":{"alert":"`+strings.Repeat("a", 5000)+`"}}`).Reason)
+}
+
+func TestServerScript(t *testing.T) {
+	server := goapnstest.NewServer()
+	defer server.Close()
+	conn := server.Connection(clientCertificate(t))
+
+	since := time.Unix(1500000000, 0)
+	server.Script(token, goapnstest.Unregistered(since))
+	response, err := conn.Send(context.Background(), goapns.NewMessage().Body("body"), token)
+	assert.Equal(t, goapns.ErrorUnregistered, err)
+	assert.Equal(t, http.StatusGone, response.StatusCode)
+	assert.True(t, since.Equal(response.Timestamp()))
+
+	//The script is used up.
+	_, err = conn.Send(context.Background(), goapns.NewMessage().Body("body"), token)
+	assert.Nil(t, err)
+
+	conn.RetryPolicy = goapns.NewRetryPolicy()
+	conn.RetryPolicy.BaseBackoff = time.Millisecond
+	server.Script(goapnstest.AnyToken, goapnstest.NewReply("ServiceUnavailable"), goapnstest.NewReply("Shutdown"))
+	response, err = conn.Send(context.Background(), goapns.NewMessage().Body("body"), token)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, response.Attempts)
+}
+
+func TestServerThrottleAndLatency(t *testing.T) {
+	server := goapnstest.NewServer()
+	defer server.Close()
+	conn := server.Connection(clientCertificate(t))
+
+	server.SetThrottle(2, time.Minute)
+	for i := 0; i < 2; i++ {
+		_, err := conn.Send(context.Background(), goapns.NewMessage().Body("body"), token)
+		assert.Nil(t, err)
+	}
+	_, err := conn.Send(context.Background(), goapns.NewMessage().Body("body"), token)
+	assert.Equal(t, goapns.ErrorTooManyRequests, err)
+	server.SetThrottle(0, 0)
+
+	server.SetLatency(500 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = conn.Send(ctx, goapns.NewMessage().Body("body"), token)
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestServerGoAway(t *testing.T) {
+	server := goapnstest.NewServer()
+	defer server.Close()
+	conn := server.Connection(clientCertificate(t))
+
+	_, err := conn.Send(context.Background(), goapns.NewMessage().Body("body"), token)
+	assert.Nil(t, err)
+
+	server.GoAway()
+	//The client needs a moment to read the GOAWAY frame.
+	time.Sleep(100 * time.Millisecond)
+
+	_, err = conn.Send(context.Background(), goapns.NewMessage().Body("body"), token)
+	assert.Nil(t, err)
+
+	notifications := server.Notifications()
+	assert.Len(t, notifications, 2)
+	assert.NotEqual(t, notifications[0].RemoteAddr, notifications[1].RemoteAddr)
+
+	server.Reset()
+	assert.Empty(t, server.Notifications())
+}